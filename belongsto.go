@@ -0,0 +1,303 @@
+package gorma
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// parseBelongsTo parses the "#belongsto" metadata of a model. Its value lists
+// the parents separated by commas, each made of up to five parts separated by
+// colons: the name of the parent model followed by the optional role, foreign
+// key column, foreign key Go type and "null" keyword, e.g.
+// "User:Reviewer:reviewer_id:int64:null". Empty parts take their default
+// value: the role is the parent name, the column is the snake case role
+// followed by "_id" and the type is the type of the primary key of the parent.
+func parseBelongsTo(v *design.APIVersionDefinition, typeName string, md design.MetadataDefinition) ([]BelongsTo, error) {
+	var belongs []BelongsTo
+	roles := make(map[string]bool)
+	for _, entry := range metaList(md, BELONGSTO) {
+		fail := func(format string, a ...interface{}) error {
+			return &GenerationError{TypeName: typeName, Key: BELONGSTO, Err: fmt.Errorf(format, a...)}
+		}
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		if len(parts) > 5 || parts[0] == "" {
+			return nil, fail("malformed entry %q, expected Parent[:role[:column[:type[:null]]]]", entry)
+		}
+		for len(parts) < 5 {
+			parts = append(parts, "")
+		}
+		bt := BelongsTo{
+			Parent: parts[0],
+			Role:   codegen.Goify(parts[1], true),
+			Column: parts[2],
+		}
+		if parts[3] != "" {
+			var err error
+			if bt.KeyType, bt.KeyPackage, err = parseKeyType(parts[3]); err != nil {
+				return nil, fail("%s in %q", err, entry)
+			}
+		}
+		switch parts[4] {
+		case "":
+		case "null":
+			bt.Nullable = true
+		default:
+			return nil, fail("invalid nullability %q in %q, expected null", parts[4], entry)
+		}
+		if bt.Role == "" {
+			bt.Role = bt.Parent
+		}
+		if roles[bt.Role] {
+			return nil, fail("duplicate role %q, name the roles of the relations to %s", bt.Role, bt.Parent)
+		}
+		roles[bt.Role] = true
+		bt.DatabaseField = camelToSnake(bt.Role)
+		if bt.Column == "" {
+			bt.Column = bt.DatabaseField + "_id"
+		}
+		if bt.KeyType == "" {
+			key := modelKey(v, bt.Parent)
+			bt.KeyType, bt.KeyPackage = key.Type, key.Package
+		}
+		belongs = append(belongs, bt)
+	}
+	return belongs, nil
+}
+
+// parseKeyType parses a key type given in the metadata: a builtin integer
+// type, string or a type qualified with the import path of its package, e.g.
+// "github.com/google/uuid.UUID". It returns the Go type, e.g. "uuid.UUID",
+// and the import path of the package, empty for builtin types.
+func parseKeyType(spec string) (typ, pkg string, err error) {
+	switch spec {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "string":
+		return spec, "", nil
+	}
+	i := strings.LastIndex(spec, ".")
+	if i < 0 || strings.LastIndex(spec, "/") > i || !isIdentifier(spec[i+1:]) || !isImportPath(spec[:i]) {
+		return "", "", fmt.Errorf("unknown key type %q, expected an integer type, string or a type qualified with the import path of its package, e.g. github.com/google/uuid.UUID", spec)
+	}
+	pkg = spec[:i]
+	name := keyPackageName(pkg)
+	if name == "" {
+		return "", "", fmt.Errorf("can't derive a package name from the import path of key type %q", spec)
+	}
+	return name + "." + spec[i+1:], pkg, nil
+}
+
+// isImportPath returns true if s is made of slash separated elements of
+// letters, digits and "-._~", the first one being a domain name as in the
+// import paths of packages outside the standard library.
+func isImportPath(s string) bool {
+	elems := strings.Split(s, "/")
+	if !strings.Contains(elems[0], ".") {
+		return false
+	}
+	for _, elem := range elems {
+		if elem == "" || strings.IndexFunc(elem, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-._~", r)
+		}) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// keyPackageName returns the name the generated code imports the package pkg
+// of a key type as: the last element of the import path that isn't a major
+// version, without a ".v<N>" version suffix, a "go-" or "go." prefix and a
+// "-go" or ".go" suffix, e.g. "uuid" for "github.com/satori/go.uuid",
+// "github.com/gofrs/uuid/v4" or "gopkg.in/uuid.v1".
+func keyPackageName(pkg string) string {
+	elems := strings.Split(pkg, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.LastIndex(name, "."); i > 0 && isMajorVersion(name[i+1:]) {
+		name = name[:i]
+	}
+	for _, affix := range []string{"go-", "go."} {
+		name = strings.TrimPrefix(name, affix)
+	}
+	for _, affix := range []string{"-go", ".go"} {
+		name = strings.TrimSuffix(name, affix)
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '~' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+	if !isIdentifier(name) {
+		return ""
+	}
+	return name
+}
+
+// isMajorVersion returns true if s is a major version, e.g. "v2".
+func isMajorVersion(s string) bool {
+	return len(s) > 1 && s[0] == 'v' && strings.Trim(s[1:], "0123456789") == ""
+}
+
+// keyImports returns the imports of the packages of the given key types,
+// sorted by import path.
+func keyImports(pkgs ...string) []*codegen.ImportSpec {
+	sort.Strings(pkgs)
+	var imports []*codegen.ImportSpec
+	for i, pkg := range pkgs {
+		if pkg != "" && (i == 0 || pkg != pkgs[i-1]) {
+			imports = append(imports, codegen.NewImport(keyPackageName(pkg), pkg))
+		}
+	}
+	return imports
+}
+
+// isIdentifier returns true if s is a Go identifier.
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// helperBelongsTo returns the "#belongsto" relations of a media type or
+// resource, whose helpers are generated in the package of the model. Malformed
+// entries are left out: validateRelations reports those of the models before
+// any helper is rendered.
+func helperBelongsTo(v *design.APIVersionDefinition, typeName string, md design.MetadataDefinition) []BelongsTo {
+	belongs, _ := parseBelongsTo(v, typeName, md)
+	return belongs
+}
+
+// belongsToParents returns the names of the parent models listed in
+// "#belongsto" metadata.
+func belongsToParents(md design.MetadataDefinition) []string {
+	var parents []string
+	for _, entry := range metaList(md, BELONGSTO) {
+		parents = append(parents, strings.TrimSpace(strings.SplitN(entry, ":", 2)[0]))
+	}
+	return parents
+}
+
+// childRelations returns the relations through which the child model belongs
+// to the parent model.
+func childRelations(v *design.APIVersionDefinition, child *design.UserTypeDefinition, parent string) []BelongsTo {
+	belongs, err := parseBelongsTo(v, child.TypeName, child.Metadata)
+	if err != nil {
+		// reported by validateRelations
+		return nil
+	}
+	var rels []BelongsTo
+	for _, bt := range belongs {
+		if bt.Parent == parent {
+			rels = append(rels, bt)
+		}
+	}
+	return rels
+}
+
+// joinRelations returns the relations through which the join model of a
+// many2many relationship between parent and child belongs to them.
+func joinRelations(v *design.APIVersionDefinition, join *design.UserTypeDefinition, parent, child string) (BelongsTo, BelongsTo, error) {
+	var toParent, toChild *BelongsTo
+	belongs, _ := parseBelongsTo(v, join.TypeName, join.Metadata)
+	for i, bt := range belongs {
+		switch {
+		case bt.Parent == parent && toParent == nil:
+			toParent = &belongs[i]
+		case bt.Parent == child && toChild == nil:
+			toChild = &belongs[i]
+		}
+	}
+	for _, r := range []struct {
+		bt   *BelongsTo
+		name string
+	}{{toParent, parent}, {toChild, child}} {
+		if r.bt == nil {
+			return BelongsTo{}, BelongsTo{}, fmt.Errorf("join model %q does not declare %s %q", modelName(join), BELONGSTO, r.name)
+		}
+		if r.bt.Nullable {
+			return BelongsTo{}, BelongsTo{}, fmt.Errorf("the %s relation of join model %q to %q can't be nullable", BELONGSTO, modelName(join), r.name)
+		}
+	}
+	return *toParent, *toChild, nil
+}
+
+// setParentField sets the ParentField and ParentKey of the relation bt of the
+// model called child, unless the parent has no single primary key or holding
+// it would form an import cycle: the package of the parent must not import the
+// package of the child, directly or through other relations. It returns true
+// if the field is set.
+func setParentField(v *design.APIVersionDefinition, child string, bt *BelongsTo) bool {
+	parent := lookupModel(v, bt.Parent)
+	if parent == nil {
+		return false
+	}
+	pks, err := getPrimaryKeys(parent)
+	if err != nil || len(pks) != 1 {
+		return false
+	}
+	if bt.Parent != child && modelImports(v, child, bt.Parent) {
+		return false
+	}
+	bt.ParentField = bt.Role
+	bt.ParentKey = codegen.Goify(pks[0].Field, true)
+	return true
+}
+
+// modelImports returns true if the package of the model called from imports
+// the package of the model called to, directly or through other models, when
+// leaving out the fields holding the parents of to. Leaving them out keeps
+// the parent fields of the models from forming a cycle together.
+func modelImports(v *design.APIVersionDefinition, to, from string) bool {
+	graph := make(map[string][]string)
+	v.IterateUserTypes(func(utd *design.UserTypeDefinition) error {
+		if !modelMetadata(utd.Definition()) {
+			return nil
+		}
+		name := modelName(utd)
+		graph[name] = append(graph[name], metaList(utd.Metadata, HASMANY)...)
+		graph[name] = append(graph[name], metaList(utd.Metadata, HASONE)...)
+		for _, entry := range metaList(utd.Metadata, M2M) {
+			if parts := strings.Split(entry, ":"); len(parts) >= 3 {
+				graph[name] = append(graph[name], parts[1:2]...)
+				if len(parts) == 4 {
+					graph[name] = append(graph[name], parts[3])
+				}
+			}
+		}
+		if name != to {
+			graph[name] = append(graph[name], belongsToParents(utd.Metadata)...)
+		}
+		return nil
+	})
+	seen := make(map[string]bool)
+	var reach func(n string) bool
+	reach = func(n string) bool {
+		if n == to {
+			return true
+		}
+		if seen[n] {
+			return false
+		}
+		seen[n] = true
+		for _, m := range graph[n] {
+			if reach(m) {
+				return true
+			}
+		}
+		return false
+	}
+	return reach(from)
+}
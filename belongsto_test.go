@@ -0,0 +1,171 @@
+package gorma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestParseBelongsTo(t *testing.T) {
+	account := newTestModel("Account", nil)
+	account.Type = design.Object{"uuid": &design.AttributeDefinition{
+		Type:     design.String,
+		Metadata: design.MetadataDefinition{META_NAMESPACE + GORMTAG: "primary_key"},
+	}}
+	v := newTestVersion(newTestModel("User", nil), account)
+	user := BelongsTo{Parent: "User", Role: "User", DatabaseField: "user", Column: "user_id", KeyType: "int"}
+	cases := []struct {
+		name  string
+		value string
+		want  []BelongsTo
+		err   string
+	}{
+		{
+			name:  "parent",
+			value: "User",
+			want:  []BelongsTo{user},
+		},
+		{
+			name:  "parent key type",
+			value: "Account",
+			want:  []BelongsTo{{Parent: "Account", Role: "Account", DatabaseField: "account", Column: "account_id", KeyType: "string"}},
+		},
+		{
+			name:  "roles",
+			value: "User:Author, User:reviewer",
+			want: []BelongsTo{
+				{Parent: "User", Role: "Author", DatabaseField: "author", Column: "author_id", KeyType: "int"},
+				{Parent: "User", Role: "Reviewer", DatabaseField: "reviewer", Column: "reviewer_id", KeyType: "int"},
+			},
+		},
+		{
+			name:  "all parts",
+			value: "User:Reviewer:reviewer_user_id:int64:null",
+			want:  []BelongsTo{{Parent: "User", Role: "Reviewer", DatabaseField: "reviewer", Column: "reviewer_user_id", KeyType: "int64", Nullable: true}},
+		},
+		{
+			name:  "empty parts",
+			value: "User::owner_id::null",
+			want:  []BelongsTo{{Parent: "User", Role: "User", DatabaseField: "user", Column: "owner_id", KeyType: "int", Nullable: true}},
+		},
+		{
+			name:  "empty role",
+			value: "User: ",
+			want:  []BelongsTo{user},
+		},
+		{
+			name:  "trailing colon",
+			value: "User:::",
+			want:  []BelongsTo{user},
+		},
+		{
+			name:  "package qualified type",
+			value: "User:::github.com/google/uuid.UUID",
+			want:  []BelongsTo{{Parent: "User", Role: "User", DatabaseField: "user", Column: "user_id", KeyType: "uuid.UUID", KeyPackage: "github.com/google/uuid"}},
+		},
+		{name: "empty parent", value: ":Author", err: `malformed entry ":Author"`},
+		{name: "too many parts", value: "User:Author:author_id:int:null:", err: `malformed entry "User:Author:author_id:int:null:"`},
+		{name: "unknown type", value: "User:::integer", err: `unknown key type "integer"`},
+		{name: "pointer type", value: "User:::*int", err: `unknown key type "*int"`},
+		{name: "package name", value: "User:::uuid.UUID", err: `unknown key type "uuid.UUID"`},
+		{name: "invalid nullability", value: "User::::nil", err: `invalid nullability "nil"`},
+		{name: "duplicate role", value: "User,User::owner_id", err: `duplicate role "User"`},
+	}
+	for _, c := range cases {
+		got, err := parseBelongsTo(v, "ReviewModel", design.MetadataDefinition{META_NAMESPACE + BELONGSTO: c.value})
+		switch {
+		case c.err != "":
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case !reflect.DeepEqual(got, c.want):
+			t.Errorf("%s: got %+v want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseKeyType(t *testing.T) {
+	cases := []struct {
+		spec string
+		typ  string
+		pkg  string
+		err  string
+	}{
+		{spec: "int", typ: "int"},
+		{spec: "uint64", typ: "uint64"},
+		{spec: "string", typ: "string"},
+		{spec: "github.com/google/uuid.UUID", typ: "uuid.UUID", pkg: "github.com/google/uuid"},
+		{spec: "github.com/satori/go.uuid.UUID", typ: "uuid.UUID", pkg: "github.com/satori/go.uuid"},
+		{spec: "github.com/gofrs/uuid/v4.UUID", typ: "uuid.UUID", pkg: "github.com/gofrs/uuid/v4"},
+		{spec: "gopkg.in/ulid-go.v2.ULID", typ: "ulid.ULID", pkg: "gopkg.in/ulid-go.v2"},
+		{spec: "example.com/Keys.ID", typ: "keys.ID", pkg: "example.com/Keys"},
+		{spec: "integer", err: `unknown key type "integer"`},
+		{spec: "uuid.UUID", err: `unknown key type "uuid.UUID"`},
+		{spec: "github.com/google/uuid", err: `unknown key type "github.com/google/uuid"`},
+		{spec: "github.com/google/uuid.", err: `unknown key type "github.com/google/uuid."`},
+		{spec: "github.com//uuid.UUID", err: `unknown key type "github.com//uuid.UUID"`},
+		{spec: "github.com/a b/uuid.UUID", err: `unknown key type "github.com/a b/uuid.UUID"`},
+		{spec: "example.com/keys/v2.ID", typ: "keys.ID", pkg: "example.com/keys/v2"},
+		{spec: "example.com/go-.ID", err: `can't derive a package name from the import path of key type "example.com/go-.ID"`},
+	}
+	for _, c := range cases {
+		typ, pkg, err := parseKeyType(c.spec)
+		switch {
+		case c.err != "":
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v want %q", c.spec, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.spec, err)
+		case typ != c.typ || pkg != c.pkg:
+			t.Errorf("%s: got %q, %q want %q, %q", c.spec, typ, pkg, c.typ, c.pkg)
+		}
+	}
+}
+
+func TestJoinRelations(t *testing.T) {
+	user := BelongsTo{Parent: "User", Role: "User", DatabaseField: "user", Column: "user_id", KeyType: "int"}
+	role := BelongsTo{Parent: "Role", Role: "Role", DatabaseField: "role", Column: "role_id", KeyType: "int"}
+	cases := []struct {
+		name      string
+		belongsTo string
+		toParent  BelongsTo
+		toChild   BelongsTo
+		err       string
+	}{
+		{name: "both", belongsTo: "Role,User", toParent: user, toChild: role},
+		{
+			name:      "first relation of each",
+			belongsTo: "User,Role,User:Granter",
+			toParent:  user,
+			toChild:   role,
+		},
+		{
+			name:      "roles",
+			belongsTo: "User:Member:member_id,Role",
+			toParent:  BelongsTo{Parent: "User", Role: "Member", DatabaseField: "member", Column: "member_id", KeyType: "int"},
+			toChild:   role,
+		},
+		{name: "missing parent", belongsTo: "Role", err: `join model "UserRole" does not declare #belongsto "User"`},
+		{name: "missing child", belongsTo: "User", err: `join model "UserRole" does not declare #belongsto "Role"`},
+		{name: "nullable", belongsTo: "User,Role::::null", err: `the #belongsto relation of join model "UserRole" to "Role" can't be nullable`},
+	}
+	for _, c := range cases {
+		v := newTestVersion(newTestModel("User", nil), newTestModel("Role", nil))
+		toParent, toChild, err := joinRelations(v, newTestModel("UserRole", map[string]string{BELONGSTO: c.belongsTo}), "User", "Role")
+		switch {
+		case c.err != "":
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case !reflect.DeepEqual(toParent, c.toParent) || !reflect.DeepEqual(toChild, c.toChild):
+			t.Errorf("%s: got %+v, %+v want %+v, %+v", c.name, toParent, toChild, c.toParent, c.toChild)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package gorma
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qor/inflection"
+	"github.com/raphael/goa/design"
+)
+
+// Child describes a field of a model holding the children of one of its
+// "#hasmany" or "#hasone" relations.
+type Child struct {
+	// Model is the name of the child model, e.g. "Review".
+	Model string
+	// Package is the name of the package of the child model.
+	Package string
+	// Field is the name of the struct field, e.g. "AuthorReviews".
+	Field string
+	// Name is the singular form of Field the accessors are named after,
+	// e.g. "AuthorReview".
+	Name string
+	// Many is true for "#hasmany" relations.
+	Many bool
+	// Polymorphic is the polymorphic relation through which the child
+	// belongs to the model, nil for "#belongsto" relations.
+	Polymorphic *Polymorphic
+	// BelongsTo is the relation through which the child belongs to the
+	// model when it isn't polymorphic.
+	BelongsTo BelongsTo
+}
+
+// getChildren returns the children fields of the model called typeName: one
+// per relation through which each of its "#hasmany" and "#hasone" models
+// belongs to it.
+func getChildren(v *design.APIVersionDefinition, utd *design.UserTypeDefinition, typeName, pkType string) []Child {
+	var children []Child
+	for _, rel := range []struct {
+		key  string
+		many bool
+	}{{HASMANY, true}, {HASONE, false}} {
+		for _, s := range metaList(utd.Metadata, rel.key) {
+			c := Child{Model: s, Package: lower(s), Many: rel.many}
+			var belongs []BelongsTo
+			if child := lookupModel(v, s); child != nil {
+				belongs = childRelations(v, child, typeName)
+				if p, ok := polymorphicRelation(child); ok && len(belongs) == 0 {
+					c.Polymorphic = &p
+				}
+			}
+			if len(belongs) == 0 && c.Polymorphic == nil {
+				// unknown child, reported by validateRelations
+				belongs = []BelongsTo{{
+					Parent:        typeName,
+					Role:          typeName,
+					DatabaseField: camelToSnake(typeName),
+					Column:        camelToSnake(typeName) + "_id",
+					KeyType:       pkType,
+				}}
+			}
+			if c.Polymorphic != nil {
+				belongs = []BelongsTo{{}}
+			}
+			for _, bt := range belongs {
+				c.BelongsTo = bt
+				c.Name = childField(bt, s)
+				c.Field = c.Name
+				if c.Many {
+					c.Field = childField(bt, inflection.Plural(s))
+				}
+				children = append(children, c)
+			}
+		}
+	}
+	return children
+}
+
+// childField returns the name of the field holding the children related to
+// the model through bt: name, prefixed with the role of the relation unless it
+// is the parent name.
+func childField(bt BelongsTo, name string) string {
+	if bt.Role == bt.Parent {
+		return name
+	}
+	return bt.Role + name
+}
+
+// childTag returns the gorm tag of a children field.
+func childTag(c Child) string {
+	switch {
+	case c.Polymorphic != nil:
+		return fmt.Sprintf("\t`gorm:\"polymorphic:%s;\"`", c.Polymorphic.Name)
+	case c.BelongsTo.Role != c.BelongsTo.Parent:
+		return fmt.Sprintf("\t`gorm:\"ForeignKey:%sID\"`", c.BelongsTo.Role)
+	}
+	return ""
+}
+
+// childFilter returns the call to Where selecting the children of the model
+// whose key is held in the parentID variable.
+func childFilter(md *ModelData, c Child) string {
+	if c.Polymorphic != nil {
+		col := camelToSnake(c.Polymorphic.Name)
+		return fmt.Sprintf("Where(\"%s_type = ? AND %s_id = ?\", m.Db.NewScope(&%s{}).TableName(), parentID)", col, col, md.TypeName)
+	}
+	return fmt.Sprintf("Where(\"%s = ?\", parentID)", c.BelongsTo.Column)
+}
+
+// childAssign returns the statements relating the child held in the child
+// variable to the model whose key is held in the parentID variable.
+func childAssign(md *ModelData, c Child) string {
+	if p := c.Polymorphic; p != nil {
+		return fmt.Sprintf("child.%sID = %s(parentID)\nchild.%sType = m.Db.NewScope(&%s{}).TableName()", p.Name, p.KeyType, p.Name, md.TypeName)
+	}
+	bt := c.BelongsTo
+	if bt.Nullable {
+		return fmt.Sprintf("fk := %s(parentID)\nchild.%sID = &fk", bt.KeyType, bt.Role)
+	}
+	return fmt.Sprintf("child.%sID = %s(parentID)", bt.Role, bt.KeyType)
+}
+
+// childFields returns the declarations of the children fields of a model.
+func childFields(md *ModelData) string {
+	var fields []string
+	for _, c := range md.Children {
+		typ := c.Package + "." + c.Model
+		if c.Many {
+			typ = "[]" + typ
+		}
+		fields = append(fields, c.Field+" "+typ+childTag(c))
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Join(fields, "\n") + "\n"
+}
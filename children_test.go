@@ -0,0 +1,82 @@
+package gorma
+
+import "testing"
+
+func TestChildFields(t *testing.T) {
+	owner := BelongsTo{Parent: "User", Role: "User", Column: "user_id", KeyType: "int"}
+	author := BelongsTo{Parent: "User", Role: "Author", Column: "author_id", KeyType: "int"}
+	cases := []struct {
+		name     string
+		children []Child
+		want     string
+	}{
+		{name: "none"},
+		{
+			name:     "hasmany",
+			children: []Child{{Model: "Proposal", Package: "proposal", Field: "Proposals", Many: true, BelongsTo: owner}},
+			want:     "Proposals []proposal.Proposal\n",
+		},
+		{
+			name:     "hasone",
+			children: []Child{{Model: "Address", Package: "address", Field: "Address", BelongsTo: owner}},
+			want:     "Address address.Address\n",
+		},
+		{
+			name: "roles",
+			children: []Child{
+				{Model: "Review", Package: "review", Field: "AuthorReviews", Many: true, BelongsTo: author},
+				{Model: "Profile", Package: "profile", Field: "AuthorProfile", BelongsTo: author},
+			},
+			want: "AuthorReviews []review.Review\t`gorm:\"ForeignKey:AuthorID\"`\n" +
+				"AuthorProfile profile.Profile\t`gorm:\"ForeignKey:AuthorID\"`\n",
+		},
+		{
+			name: "polymorphic",
+			children: []Child{
+				{Model: "Comment", Package: "comment", Field: "Comments", Many: true, Polymorphic: &Polymorphic{Name: "Commentable", KeyType: "int"}},
+				{Model: "Image", Package: "image", Field: "Image", Polymorphic: &Polymorphic{Name: "Imageable", KeyType: "int"}},
+			},
+			want: "Comments []comment.Comment\t`gorm:\"polymorphic:Commentable;\"`\n" +
+				"Image image.Image\t`gorm:\"polymorphic:Imageable;\"`\n",
+		},
+	}
+	for _, c := range cases {
+		if got := childFields(&ModelData{TypeName: "User", Children: c.children}); got != c.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestChildAssign(t *testing.T) {
+	cases := []struct {
+		name  string
+		child Child
+		want  string
+	}{
+		{
+			name:  "belongsto",
+			child: Child{BelongsTo: BelongsTo{Parent: "User", Role: "User", KeyType: "int64"}},
+			want:  "child.UserID = int64(parentID)",
+		},
+		{
+			name:  "role",
+			child: Child{BelongsTo: BelongsTo{Parent: "User", Role: "Author", KeyType: "int"}},
+			want:  "child.AuthorID = int(parentID)",
+		},
+		{
+			name:  "nullable",
+			child: Child{BelongsTo: BelongsTo{Parent: "User", Role: "Reviewer", KeyType: "string", Nullable: true}},
+			want:  "fk := string(parentID)\nchild.ReviewerID = &fk",
+		},
+		{
+			name:  "polymorphic",
+			child: Child{Polymorphic: &Polymorphic{Name: "Commentable", KeyType: "int"}},
+			want:  "child.CommentableID = int(parentID)\nchild.CommentableType = m.Db.NewScope(&User{}).TableName()",
+		},
+	}
+	for _, c := range cases {
+		if got := childAssign(&ModelData{TypeName: "User"}, c.child); got != c.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gorma
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// Check is a CHECK constraint derived from the Minimum and Maximum validations
+// of an attribute. Gorm doesn't create CHECK constraints from struct tags, the
+// generated AddConstraints method creates them.
+type Check struct {
+	// Name is the name of the constraint, e.g. "chk_user_age".
+	Name string
+	// Column is the constrained column.
+	Column string
+	// Expr is the SQL condition, e.g. "age >= 0 AND age <= 150".
+	Expr string
+}
+
+// constraintTags returns the sql tag settings derived from the validations of
+// the attribute called name of the object def: "size" from MaxLength, "not
+// null" from Required and "default" from the default value.
+func constraintTags(def *design.AttributeDefinition, name string) []string {
+	att := def.Type.ToObject()[name]
+	var tags []string
+	for _, v := range att.Validations {
+		if actual, ok := v.(*design.MaxLengthValidationDefinition); ok && att.Type.Kind() == design.StringKind {
+			tags = append(tags, fmt.Sprintf("size:%d", actual.MaxLength))
+		}
+	}
+	if def.IsRequired(name) {
+		tags = append(tags, "not null")
+	}
+	if att.DefaultValue != nil {
+		tags = append(tags, "default:"+sqlLiteral(att.DefaultValue))
+	}
+	return tags
+}
+
+// getChecks returns the CHECK constraints of the model typeName derived from
+// the Minimum and Maximum validations of its attributes, in attribute order.
+func getChecks(typeName string, def *design.AttributeDefinition) []Check {
+	obj := def.Type.ToObject()
+	names := make([]string, 0, len(obj))
+	for n := range obj {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var checks []Check
+	for _, n := range names {
+		column := camelToSnake(codegen.Goify(n, true))
+		var conds []string
+		for _, v := range obj[n].Validations {
+			switch actual := v.(type) {
+			case *design.MinimumValidationDefinition:
+				conds = append(conds, fmt.Sprintf("%s >= %s", column, formatNumber(actual.Min)))
+			case *design.MaximumValidationDefinition:
+				conds = append(conds, fmt.Sprintf("%s <= %s", column, formatNumber(actual.Max)))
+			}
+		}
+		if len(conds) > 0 {
+			checks = append(checks, Check{
+				Name:   "chk_" + camelToSnake(typeName) + "_" + column,
+				Column: column,
+				Expr:   strings.Join(conds, " AND "),
+			})
+		}
+	}
+	return checks
+}
+
+// checkDefaults returns an error if a default value of the model utd can't be
+// written in a sql tag: gorm splits the tag settings on ";" and the tag itself
+// is a raw string holding a quoted value, so those characters, backslashes and
+// control characters are rejected, as are defaults of non scalar types.
+func checkDefaults(utd *design.UserTypeDefinition) error {
+	obj := utd.Type.ToObject()
+	names := make([]string, 0, len(obj))
+	for n := range obj {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		var err error
+		switch actual := obj[n].DefaultValue.(type) {
+		case nil, bool, int, float64:
+		case string:
+			if i := strings.IndexFunc(actual, func(r rune) bool {
+				return r == ';' || r == '"' || r == '`' || r == '\\' || unicode.IsControl(r)
+			}); i >= 0 {
+				r, _ := utf8.DecodeRuneInString(actual[i:])
+				err = fmt.Errorf("default value %q contains %q, which can't be written in a sql tag", actual, r)
+			}
+		default:
+			err = fmt.Errorf("default value %v is not a string, number or boolean", actual)
+		}
+		if err != nil {
+			return &GenerationError{TypeName: utd.TypeName, Attribute: n, Err: err}
+		}
+	}
+	return nil
+}
+
+// formatNumber returns the shortest representation of a number.
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// sqlLiteral returns the SQL literal of a default value.
+func sqlLiteral(v interface{}) string {
+	switch actual := v.(type) {
+	case string:
+		return "'" + strings.Replace(actual, "'", "''", -1) + "'"
+	case float64:
+		return formatNumber(actual)
+	default:
+		return fmt.Sprint(actual)
+	}
+}
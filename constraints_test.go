@@ -0,0 +1,130 @@
+package gorma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestConstraintTags(t *testing.T) {
+	def := &design.AttributeDefinition{
+		Type: design.Object{
+			"bio": &design.AttributeDefinition{
+				Type:        design.String,
+				Validations: []design.ValidationDefinition{&design.MaxLengthValidationDefinition{MaxLength: 500}},
+			},
+			"tags": &design.AttributeDefinition{
+				Type:        &design.Array{ElemType: &design.AttributeDefinition{Type: design.String}},
+				Validations: []design.ValidationDefinition{&design.MaxLengthValidationDefinition{MaxLength: 5}},
+			},
+			"age": &design.AttributeDefinition{
+				Type:         design.Integer,
+				Validations:  []design.ValidationDefinition{&design.MinimumValidationDefinition{Min: 0}, &design.MaximumValidationDefinition{Max: 150}},
+				DefaultValue: 18,
+			},
+			"nick":   &design.AttributeDefinition{Type: design.String, DefaultValue: "o'k"},
+			"active": &design.AttributeDefinition{Type: design.Boolean, DefaultValue: true},
+		},
+		Validations: []design.ValidationDefinition{&design.RequiredValidationDefinition{Names: []string{"bio", "nick"}}},
+	}
+	cases := []struct {
+		name string
+		want []string
+	}{
+		{"bio", []string{"size:500", "not null"}},
+		{"tags", nil},
+		{"age", []string{"default:18"}},
+		{"nick", []string{"not null", "default:'o''k'"}},
+		{"active", []string{"default:true"}},
+	}
+	for _, c := range cases {
+		if got := constraintTags(def, c.name); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGetChecks(t *testing.T) {
+	def := &design.AttributeDefinition{Type: design.Object{
+		"age": &design.AttributeDefinition{Type: design.Integer, Validations: []design.ValidationDefinition{
+			&design.MinimumValidationDefinition{Min: 0},
+			&design.MaximumValidationDefinition{Max: 150},
+		}},
+		"score": &design.AttributeDefinition{Type: design.Number, Validations: []design.ValidationDefinition{
+			&design.MinimumValidationDefinition{Min: 0.5},
+		}},
+		"rankValue": &design.AttributeDefinition{Type: design.Integer, Validations: []design.ValidationDefinition{
+			&design.MaximumValidationDefinition{Max: -1},
+		}},
+		"bio": &design.AttributeDefinition{Type: design.String, Validations: []design.ValidationDefinition{
+			&design.MaxLengthValidationDefinition{MaxLength: 500},
+		}},
+	}}
+	want := []Check{
+		{Name: "chk_user_profile_age", Column: "age", Expr: "age >= 0 AND age <= 150"},
+		{Name: "chk_user_profile_rank_value", Column: "rank_value", Expr: "rank_value <= -1"},
+		{Name: "chk_user_profile_score", Column: "score", Expr: "score >= 0.5"},
+	}
+	if got := getChecks("UserProfile", def); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+	if got := getChecks("User", &design.AttributeDefinition{Type: design.Object{}}); got != nil {
+		t.Errorf("no validations: got %+v", got)
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{"", "''"},
+		{"active", "'active'"},
+		{"it's", "'it''s'"},
+		{"''", "''''''"},
+		{42, "42"},
+		{-1.5, "-1.5"},
+		{1e21, "1000000000000000000000"},
+		{false, "false"},
+	}
+	for _, c := range cases {
+		if got := sqlLiteral(c.value); got != c.want {
+			t.Errorf("%#v: got %s want %s", c.value, got, c.want)
+		}
+	}
+}
+
+func TestCheckDefaults(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{name: "string", value: "o'k: fine"},
+		{name: "number", value: 1.5},
+		{name: "integer", value: 3},
+		{name: "boolean", value: true},
+		{name: "semicolon", value: "a;b", err: `attribute bio: default value "a;b" contains ';'`},
+		{name: "double quote", value: `a"b`, err: `contains '"'`},
+		{name: "backquote", value: "a`b", err: "contains '`'"},
+		{name: "backslash", value: `a\b`, err: `contains '\\'`},
+		{name: "newline", value: "a\nb", err: `contains '\n'`},
+		{name: "array", value: []interface{}{"a"}, err: "default value [a] is not a string, number or boolean"},
+	}
+	for _, c := range cases {
+		utd := newTestModel("User", nil)
+		utd.Type = design.Object{
+			"age": &design.AttributeDefinition{Type: design.Integer, DefaultValue: 18},
+			"bio": &design.AttributeDefinition{Type: design.String, DefaultValue: c.value},
+		}
+		err := checkDefaults(utd)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package gorma
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChangeKind describes what a generator run does to a file.
+type ChangeKind string
+
+const (
+	// FileCreated is a file that doesn't exist yet.
+	FileCreated ChangeKind = "created"
+	// FileChanged is an existing file whose content differs.
+	FileChanged ChangeKind = "changed"
+	// FileDeleted is an existing file the generator removes.
+	FileDeleted ChangeKind = "deleted"
+)
+
+// FileChange is a change to a generated file reported by a dry run.
+type FileChange struct {
+	// Path is the path of the file relative to the output directory.
+	Path string
+	Kind ChangeKind
+	// Diff is the unified diff between the file on disk and the
+	// generated content.
+	Diff string
+}
+
+// diffFiles compares the files rendered in the scratch directory with their
+// counterparts on disk. The stale files are reported as deleted.
+func (g *Generator) diffFiles(stale []string) ([]FileChange, error) {
+	var changes []FileChange
+	for _, f := range g.genfiles {
+		rel, err := filepath.Rel(g.OutputDir, f)
+		if err != nil {
+			rel = f
+		}
+		rel = filepath.ToSlash(rel)
+		generated, err := ioutil.ReadFile(g.target(f))
+		if err != nil {
+			return nil, err
+		}
+		existing, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) {
+			changes = append(changes, FileChange{
+				Path: rel,
+				Kind: FileCreated,
+				Diff: unifiedDiff("/dev/null", "b/"+rel, "", string(generated)),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(existing, generated) {
+			continue
+		}
+		changes = append(changes, FileChange{
+			Path: rel,
+			Kind: FileChanged,
+			Diff: unifiedDiff("a/"+rel, "b/"+rel, string(existing), string(generated)),
+		})
+	}
+	for _, f := range stale {
+		rel, err := filepath.Rel(g.OutputDir, f)
+		if err != nil {
+			rel = f
+		}
+		rel = filepath.ToSlash(rel)
+		existing, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, FileChange{
+			Path: rel,
+			Kind: FileDeleted,
+			Diff: unifiedDiff("a/"+rel, "/dev/null", string(existing), ""),
+		})
+	}
+	return changes, nil
+}
+
+// reportChanges writes a summary of the changes followed by their diffs.
+func reportChanges(w io.Writer, changes []FileChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "gorma: dry run, generated files are up to date")
+		return
+	}
+	fmt.Fprintln(w, "gorma: dry run, no files written")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%-8s %s\n", c.Kind, c.Path)
+	}
+	for _, c := range changes {
+		fmt.Fprint(w, c.Diff)
+	}
+}
+
+// unifiedDiff returns the unified diff turning a into b with three lines of
+// context.
+func unifiedDiff(fromName, toName, a, b string) string {
+	const context = 3
+	al, bl := splitLines(a), splitLines(b)
+
+	// trim the common prefix and suffix so the LCS table stays small
+	pre := 0
+	for pre < len(al) && pre < len(bl) && al[pre] == bl[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(al)-pre && suf < len(bl)-pre && al[len(al)-1-suf] == bl[len(bl)-1-suf] {
+		suf++
+	}
+	ma, mb := al[pre:len(al)-suf], bl[pre:len(bl)-suf]
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// ops lists every line of both files: ' ' kept, '-' removed, '+' added
+	type op struct {
+		kind byte
+		line string
+	}
+	var ops []op
+	for _, l := range al[:pre] {
+		ops = append(ops, op{' ', l})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, op{' ', ma[i]})
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', ma[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', mb[j]})
+			j++
+		}
+	}
+	for _, l := range al[len(al)-suf:] {
+		ops = append(ops, op{' ', l})
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		// extend the hunk while changes are separated by at most
+		// 2*context unchanged lines
+		end, kept := start, 0
+		for k := start; k < len(ops) && kept <= 2*context; k++ {
+			if ops[k].kind == ' ' {
+				kept++
+			} else {
+				kept = 0
+				end = k + 1
+			}
+		}
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context
+		if to > len(ops) {
+			to = len(ops)
+		}
+		aStart, bStart := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				aStart++
+			}
+			if o.kind != '-' {
+				bStart++
+			}
+		}
+		var aLen, bLen int
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				aLen++
+			}
+			if o.kind != '-' {
+				bLen++
+			}
+		}
+		if aLen == 0 {
+			aStart--
+		}
+		if bLen == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, o := range ops[from:to] {
+			buf.WriteByte(o.kind)
+			buf.WriteString(o.line)
+			buf.WriteByte('\n')
+		}
+		start = to
+	}
+	return buf.String()
+}
+
+// splitLines splits s in lines, dropping the final newline. A last line
+// without newline carries the marker diff prints after it, so that it differs
+// from the same line followed by a newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if !strings.HasSuffix(s, "\n") {
+		lines[len(lines)-1] += "\n\\ No newline at end of file"
+	}
+	return lines
+}
@@ -0,0 +1,79 @@
+package gorma
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns the lines "1" to "n", each followed by a newline,
+// with the given lines replaced.
+func numberedLines(n int, replace map[int]string) string {
+	var lines []string
+	for i := 1; i <= n; i++ {
+		l, ok := replace[i]
+		if !ok {
+			l = strconv.Itoa(i)
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "x\ny\n",
+			b:    "x\ny\n",
+			want: "",
+		},
+		{
+			name: "insertion",
+			a:    "",
+			b:    "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "deletion",
+			a:    "a\nb\n",
+			b:    "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "context",
+			a:    numberedLines(10, nil),
+			b:    "1\n2\n3\n4\n5\nx\n6\n7\n8\n9\n10\n",
+			want: "@@ -3,6 +3,7 @@\n 3\n 4\n 5\n+x\n 6\n 7\n 8\n",
+		},
+		{
+			name: "merged hunks",
+			a:    numberedLines(20, nil),
+			b:    numberedLines(20, map[int]string{3: "c", 9: "i"}),
+			want: "@@ -1,12 +1,12 @@\n 1\n 2\n-3\n+c\n 4\n 5\n 6\n 7\n 8\n-9\n+i\n 10\n 11\n 12\n",
+		},
+		{
+			name: "separate hunks",
+			a:    numberedLines(20, nil),
+			b:    numberedLines(20, map[int]string{3: "c", 11: "k"}),
+			want: "@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+c\n 4\n 5\n 6\n" +
+				"@@ -8,7 +8,7 @@\n 8\n 9\n 10\n-11\n+k\n 12\n 13\n 14\n",
+		},
+		{
+			name: "missing trailing newline",
+			a:    "x\ny\n",
+			b:    "x\ny",
+			want: "@@ -1,2 +1,2 @@\n x\n-y\n+y\n\\ No newline at end of file\n",
+		},
+	}
+	for _, c := range cases {
+		want := "--- a/f\n+++ b/f\n" + c.want
+		if got := unifiedDiff("a/f", "b/f", c.a, c.b); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package gorma
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// Enum describes the named type generated for an attribute with an Enum
+// validation.
+type Enum struct {
+	// TypeName is the name of the generated type, e.g. "UserStatus".
+	TypeName string
+	// Attribute is the name of the attribute.
+	Attribute string
+	// Field is the name of the struct field.
+	Field string
+	// Base is the underlying Go type, "string" or "int".
+	Base string
+	// Pointer is true if the struct field is a pointer.
+	Pointer bool
+	// Values lists the enum values in declaration order.
+	Values []EnumValue
+}
+
+// EnumValue is one of the values of an enum.
+type EnumValue struct {
+	// Const is the name of the constant holding the value.
+	Const string
+	// Literal is the Go literal of the value.
+	Literal string
+}
+
+// getEnums returns the enums of the string and integer attributes of a model
+// sorted by attribute name.
+func getEnums(typeName string, def *design.AttributeDefinition) []Enum {
+	var enums []Enum
+	obj := def.Type.ToObject()
+	if obj == nil {
+		return nil
+	}
+	obj.IterateAttributes(func(n string, att *design.AttributeDefinition) error {
+		values := enumValues(att)
+		if values == nil {
+			return nil
+		}
+		var base string
+		switch att.Type.Kind() {
+		case design.StringKind:
+			base = "string"
+		case design.IntegerKind:
+			base = "int"
+		default:
+			return nil
+		}
+		e := Enum{
+			TypeName:  typeName + codegen.Goify(n, true),
+			Attribute: n,
+			Field:     codegen.Goify(n, true),
+			Base:      base,
+			Pointer:   def.IsPrimitivePointer(n),
+		}
+		seen := make(map[string]bool)
+		for _, v := range values {
+			lit := fmt.Sprintf("%#v", v)
+			name := e.TypeName + enumConstSuffix(v)
+			for i := 2; seen[name]; i++ {
+				name = fmt.Sprintf("%s%s%d", e.TypeName, enumConstSuffix(v), i)
+			}
+			seen[name] = true
+			e.Values = append(e.Values, EnumValue{Const: name, Literal: lit})
+		}
+		enums = append(enums, e)
+		return nil
+	})
+	return enums
+}
+
+// checkEnums returns an error if the name of an enum type or constant of the
+// model typeName collides with another enum or with a declaration of the
+// generated package: the model, its key, storage and filter declarations and
+// the payload helpers of its resource.
+func checkEnums(utd *design.UserTypeDefinition, typeName string, enums []Enum) error {
+	declared := map[string]string{}
+	for _, suffix := range []string{"", "Key", "Storage", "DB", "FilterByKey"} {
+		declared[typeName+suffix] = "a declaration of the model"
+	}
+	for _, e := range enums {
+		names := []string{e.TypeName}
+		for _, v := range e.Values {
+			names = append(names, v.Const)
+		}
+		for _, n := range names {
+			other, ok := declared[n]
+			switch {
+			case ok:
+			case strings.HasPrefix(n, typeName+"FilterBy"):
+				other, ok = "the "+typeName+"FilterBy* filters of the model", true
+			case strings.HasPrefix(n, typeName+"From") && strings.HasSuffix(n, "Payload"):
+				other, ok = "the "+typeName+"From*Payload helpers of the resource", true
+			}
+			if ok {
+				return &GenerationError{
+					TypeName:  utd.TypeName,
+					Attribute: e.Attribute,
+					Err:       fmt.Errorf("enum name %s collides with %s", n, other),
+				}
+			}
+			declared[n] = "the enum of attribute " + e.Attribute
+		}
+	}
+	return nil
+}
+
+// enumValues returns the values of the Enum validation of an attribute.
+func enumValues(att *design.AttributeDefinition) []interface{} {
+	for _, v := range att.Validations {
+		if ev, ok := v.(*design.EnumValidationDefinition); ok {
+			return ev.Values
+		}
+	}
+	return nil
+}
+
+// findEnum returns the enum generated for the given attribute.
+func findEnum(enums []Enum, attName string) (Enum, bool) {
+	for _, e := range enums {
+		if e.Attribute == attName {
+			return e, true
+		}
+	}
+	return Enum{}, false
+}
+
+// enumConstSuffix returns the suffix of the name of the constant holding an
+// enum value: its words in title case, e.g. "InProgress" for "in-progress".
+func enumConstSuffix(v interface{}) string {
+	s := fmt.Sprint(v)
+	if n, ok := v.(int); ok && n < 0 {
+		s = "minus" + strconv.Itoa(-n)
+	}
+	var buf bytes.Buffer
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		r, size := utf8.DecodeRuneInString(w)
+		buf.WriteRune(unicode.ToUpper(r))
+		buf.WriteString(w[size:])
+	}
+	if buf.Len() == 0 {
+		return "Empty"
+	}
+	return buf.String()
+}
+
+// enumConversions returns the statements copying the enum fields of source
+// into target. def is the definition of the struct that isn't the model, its
+// fields have the base type of the enums. toEnum is true when target is the
+// model.
+func enumConversions(enums []Enum, def *design.AttributeDefinition, target, source string, toEnum bool) string {
+	obj := def.Type.ToObject()
+	var stmts []string
+	for _, e := range enums {
+		if _, ok := obj[e.Attribute]; !ok {
+			continue
+		}
+		from, to := e.Pointer, def.IsPrimitivePointer(e.Attribute)
+		typ := e.Base
+		if toEnum {
+			from, to = to, from
+			typ = e.TypeName
+		}
+		src := fmt.Sprintf("%s.%s", source, e.Field)
+		dst := fmt.Sprintf("%s.%s", target, e.Field)
+		switch {
+		case from && to:
+			stmts = append(stmts, fmt.Sprintf("if %s != nil {\nv := %s(*%s)\n%s = &v\n}", src, typ, src, dst))
+		case from:
+			stmts = append(stmts, fmt.Sprintf("if %s != nil {\n%s = %s(*%s)\n}", src, dst, typ, src))
+		case to:
+			stmts = append(stmts, fmt.Sprintf("{\nv := %s(%s)\n%s = &v\n}", typ, src, dst))
+		default:
+			stmts = append(stmts, fmt.Sprintf("%s = %s(%s)", dst, typ, src))
+		}
+	}
+	return strings.Join(stmts, "\n")
+}
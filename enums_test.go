@@ -0,0 +1,132 @@
+package gorma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+// enumAttribute returns an attribute of type t with an Enum validation of the
+// given values.
+func enumAttribute(t design.DataType, values ...interface{}) *design.AttributeDefinition {
+	return &design.AttributeDefinition{
+		Type:        t,
+		Validations: []design.ValidationDefinition{&design.EnumValidationDefinition{Values: values}},
+	}
+}
+
+func TestGetEnums(t *testing.T) {
+	def := &design.AttributeDefinition{
+		Type: design.Object{
+			"status":   enumAttribute(design.String, "active", "in-progress", "in progress", ""),
+			"priority": enumAttribute(design.Integer, 1, -1),
+			"ratio":    enumAttribute(design.Number, 0.5),
+			"name":     &design.AttributeDefinition{Type: design.String},
+		},
+		Validations: []design.ValidationDefinition{&design.RequiredValidationDefinition{Names: []string{"status"}}},
+	}
+	want := []Enum{
+		{
+			TypeName:  "UserPriority",
+			Attribute: "priority",
+			Field:     "Priority",
+			Base:      "int",
+			Pointer:   true,
+			Values:    []EnumValue{{Const: "UserPriority1", Literal: "1"}, {Const: "UserPriorityMinus1", Literal: "-1"}},
+		},
+		{
+			TypeName:  "UserStatus",
+			Attribute: "status",
+			Field:     "Status",
+			Base:      "string",
+			Values: []EnumValue{
+				{Const: "UserStatusActive", Literal: `"active"`},
+				{Const: "UserStatusInProgress", Literal: `"in-progress"`},
+				{Const: "UserStatusInProgress2", Literal: `"in progress"`},
+				{Const: "UserStatusEmpty", Literal: `""`},
+			},
+		},
+	}
+	if got := getEnums("User", def); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEnumConstSuffix(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{"active", "Active"},
+		{"in-progress", "InProgress"},
+		{"in_progress", "InProgress"},
+		{"HTTP2", "HTTP2"},
+		{"élan", "Élan"},
+		{"über cool", "ÜberCool"},
+		{"--", "Empty"},
+		{"", "Empty"},
+		{0, "0"},
+		{-42, "Minus42"},
+	}
+	for _, c := range cases {
+		if got := enumConstSuffix(c.value); got != c.want {
+			t.Errorf("%#v: got %q want %q", c.value, got, c.want)
+		}
+	}
+}
+
+func TestCheckEnums(t *testing.T) {
+	cases := []struct {
+		name string
+		atts design.Object
+		err  string
+	}{
+		{
+			name: "no collision",
+			atts: design.Object{"status": enumAttribute(design.String, "active"), "kind": enumAttribute(design.String, "db")},
+		},
+		{
+			name: "storage",
+			atts: design.Object{"storage": enumAttribute(design.String, "disk")},
+			err:  "attribute storage: enum name FileStorage collides with a declaration of the model",
+		},
+		{
+			name: "key",
+			atts: design.Object{"key": enumAttribute(design.String, "a")},
+			err:  "enum name FileKey collides with a declaration of the model",
+		},
+		{
+			name: "filter",
+			atts: design.Object{"filter_by_key": enumAttribute(design.String, "a")},
+			err:  "enum name FileFilterByKey collides with a declaration of the model",
+		},
+		{
+			name: "belongsto filter",
+			atts: design.Object{"filter": enumAttribute(design.String, "by owner")},
+			err:  "enum name FileFilterByOwner collides with the FileFilterBy* filters of the model",
+		},
+		{
+			name: "payload helper",
+			atts: design.Object{"from_v1_create_payload": enumAttribute(design.String, "a")},
+			err:  "enum name FileFromV1CreatePayload collides with the FileFrom*Payload helpers of the resource",
+		},
+		{
+			name: "other enum",
+			atts: design.Object{"status": enumAttribute(design.String, "active"), "status_active": enumAttribute(design.String, "yes")},
+			err:  "attribute status_active: enum name FileStatusActive collides with the enum of attribute status",
+		},
+	}
+	for _, c := range cases {
+		utd := newTestModel("File", nil)
+		utd.Type = c.atts
+		err := checkEnums(utd, "File", getEnums("File", utd.Definition()))
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+		}
+	}
+}
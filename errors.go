@@ -0,0 +1,87 @@
+package gorma
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// GenerationError is a problem found while generating the code for a design
+// definition. TypeName, Attribute and Key locate the problem in the design
+// when known.
+type GenerationError struct {
+	// TypeName is the name of the user type, media type or resource.
+	TypeName string
+	// Attribute is the name of the attribute.
+	Attribute string
+	// Key is the gorma metadata key, e.g. "#belongsto".
+	Key string
+	// Err describes the problem.
+	Err error
+}
+
+// Error returns the location of the problem followed by its description.
+func (e *GenerationError) Error() string {
+	var loc []string
+	if e.TypeName != "" {
+		loc = append(loc, "type "+e.TypeName)
+	}
+	if e.Attribute != "" {
+		loc = append(loc, "attribute "+e.Attribute)
+	}
+	if e.Key != "" {
+		loc = append(loc, "metadata "+e.Key)
+	}
+	if len(loc) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(loc, ", "), e.Err)
+}
+
+// GenerationErrors is the list of problems found by a generator run.
+type GenerationErrors []*GenerationError
+
+// Error returns a report listing all the problems.
+func (errs GenerationErrors) Error() string {
+	var buf bytes.Buffer
+	if len(errs) == 1 {
+		buf.WriteString("gorma: 1 problem found:")
+	} else {
+		fmt.Fprintf(&buf, "gorma: %d problems found:", len(errs))
+	}
+	for _, e := range errs {
+		buf.WriteString("\n\t")
+		buf.WriteString(e.Error())
+	}
+	return buf.String()
+}
+
+// fail records a problem found while generating the code for the given type.
+// Errors that are already located are recorded as is. A problem found in
+// several API versions is only reported once.
+func (g *Generator) fail(typeName string, err error) {
+	var errs GenerationErrors
+	switch actual := err.(type) {
+	case *GenerationError:
+		errs = GenerationErrors{actual}
+	case GenerationErrors:
+		errs = actual
+	default:
+		errs = GenerationErrors{{TypeName: typeName, Err: err}}
+	}
+	for _, e := range errs {
+		if !g.errs.contains(e) {
+			g.errs = append(g.errs, e)
+		}
+	}
+}
+
+// contains returns true if errs already holds a problem reported as e.
+func (errs GenerationErrors) contains(e *GenerationError) bool {
+	for _, other := range errs {
+		if other.Error() == e.Error() {
+			return true
+		}
+	}
+	return false
+}
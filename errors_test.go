@@ -0,0 +1,48 @@
+package gorma
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerationError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  *GenerationError
+		want string
+	}{
+		{"unlocated", &GenerationError{Err: errors.New("boom")}, "boom"},
+		{"type", &GenerationError{TypeName: "User", Err: errors.New("boom")}, "type User: boom"},
+		{
+			"attribute and key",
+			&GenerationError{TypeName: "User", Attribute: "role_id", Key: "#belongsto", Err: errors.New("boom")},
+			"type User, attribute role_id, metadata #belongsto: boom",
+		},
+	}
+	for _, c := range cases {
+		if got := c.err.Error(); got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFail(t *testing.T) {
+	g := &Generator{}
+	g.fail("User", errors.New("bad user"))
+	g.fail("User", &GenerationError{TypeName: "User", Err: errors.New("bad user")})
+	g.fail("Account", GenerationErrors{
+		{TypeName: "Account", Key: "#pktype", Err: errors.New("bad key")},
+		{TypeName: "Account", Attribute: "name", Err: errors.New("bad name")},
+	})
+	want := "gorma: 3 problems found:" +
+		"\n\ttype User: bad user" +
+		"\n\ttype Account, metadata #pktype: bad key" +
+		"\n\ttype Account, attribute name: bad name"
+	if got := g.errs.Error(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	one := GenerationErrors{g.errs[0]}
+	if got, want := one.Error(), "gorma: 1 problem found:\n\ttype User: bad user"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -2,33 +2,48 @@ package gorma
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/raphael/goa/design"
 	"github.com/raphael/goa/goagen/codegen"
-	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 // Generator is the application code generator.
 type Generator struct {
-	genfiles []string
+	Options
+
+	plugins   []Plugin
+	genfiles  []string
+	scaffolds map[string]bool
+	scratch   string
+	changes   []FileChange
+	errs      GenerationErrors
+	warnings  GenerationErrors
 }
 
 // Generate is the generator entry point called by the meta generator.
 func Generate(api *design.APIDefinition) ([]string, error) {
-	g, err := NewGenerator()
+	opts, err := ParseFlags(os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	g, err := NewGenerator(opts)
 	if err != nil {
 		return nil, err
 	}
 	return g.Generate(api)
 }
 
-// NewGenerator returns the application code generator.
-func NewGenerator() (*Generator, error) {
-	return new(Generator), nil
+// NewGenerator returns the application code generator configured with the
+// given options.
+func NewGenerator(opts Options) (*Generator, error) {
+	if err := opts.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &Generator{Options: opts}, nil
 }
 
 // Generate produces the generated model files
@@ -36,224 +51,300 @@ func (g *Generator) Generate(api *design.APIDefinition) ([]string, error) {
 	if api == nil {
 		return nil, fmt.Errorf("missing API definition")
 	}
+	g.changes = nil
+	g.genfiles = nil
+	g.scaffolds = make(map[string]bool)
+	g.errs = nil
+	g.warnings = nil
+	if g.DryRun {
+		scratch, err := ioutil.TempDir("", "gorma")
+		if err != nil {
+			return nil, err
+		}
+		g.scratch = scratch
+		defer func() {
+			os.RemoveAll(scratch)
+			g.scratch = ""
+		}()
+	}
+
+	err := api.IterateVersions(func(v *design.APIVersionDefinition) error {
+		for _, w := range validateMetadata(v) {
+			if g.Strict {
+				g.fail("", w)
+			} else if !g.warnings.contains(w) {
+				g.warnings = append(g.warnings, w)
+				fmt.Fprintf(os.Stderr, "gorma: warning: %s\n", w)
+			}
+		}
+		for _, e := range validateRelations(v) {
+			g.fail("", e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(g.errs) > 0 {
+		return nil, g.errs
+	}
 
 	// RBAC is unversioned, do it first
 	if err := g.generateRBAC(api); err != nil {
+		g.Cleanup()
 		return nil, err
 	}
 	if err := g.generateModels(api); err != nil {
+		g.Cleanup()
+		return nil, err
+	}
+	if err := g.generateImpls(api); err != nil {
+		g.Cleanup()
 		return nil, err
 	}
-	/*	if err := g.generateImpls(api); err != nil {
-			return nil, err
-		}
-	*/
 	if err := g.generateMedia(api); err != nil {
+		g.Cleanup()
 		return nil, err
 	}
 	if err := g.generateResources(api); err != nil {
+		g.Cleanup()
+		return nil, err
+	}
+	if len(g.errs) > 0 {
+		g.Cleanup()
+		return nil, g.errs
+	}
+	stale, err := g.staleFiles()
+	if err != nil {
+		g.Cleanup()
+		return nil, err
+	}
+	if g.DryRun {
+		changes, err := g.diffFiles(stale)
+		if err != nil {
+			return nil, err
+		}
+		g.changes = changes
+		reportChanges(os.Stdout, changes)
+		g.genfiles = nil
+		return nil, nil
+	}
+	if err := g.prune(stale); err != nil {
+		return nil, err
+	}
+	if err := g.writeManifest(); err != nil {
 		return nil, err
 	}
 	return g.genfiles, nil
 }
 
-// Generate produces the generated model files
-func (g *Generator) generateModels(api *design.APIDefinition) error {
-	app := kingpin.New("Model generator", "model generator")
-	codegen.RegisterFlags(app)
-	_, err := app.Parse(os.Args[1:])
+// Warnings returns the problems with the design metadata reported by the last
+// run that didn't prevent code generation.
+func (g *Generator) Warnings() GenerationErrors {
+	return g.warnings
+}
+
+// Changes returns the changes computed by the last dry run.
+func (g *Generator) Changes() []FileChange {
+	return g.changes
+}
+
+// target returns the path the given file is rendered to: the file itself or
+// its counterpart in the scratch directory during a dry run.
+func (g *Generator) target(filename string) string {
+	if g.scratch == "" {
+		return filename
+	}
+	rel, err := filepath.Rel(g.OutputDir, filename)
 	if err != nil {
-		panic(err)
+		rel = filepath.Base(filename)
 	}
-	outdir := modelDir()
-	gopath := filepath.SplitList(os.Getenv("GOPATH"))[0]
+	return filepath.Join(g.scratch, rel)
+}
 
-	mainimp, err := filepath.Rel(filepath.Join(gopath, "src"), codegen.OutputDir)
-	if err != nil {
-		return err
+// prepareFile creates the directory of the file to be generated and removes
+// any previous version of it. It returns the path the file must be rendered to.
+func (g *Generator) prepareFile(filename string) (string, error) {
+	out := g.target(filename)
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", err
 	}
-	mainimp = filepath.ToSlash(mainimp)
-	imp := path.Join(mainimp, "app")
-	imports := []*codegen.ImportSpec{
-		codegen.SimpleImport(imp),
+	os.Remove(out)
+	return out, nil
+}
+
+// Generate produces the generated model files
+func (g *Generator) generateModels(api *design.APIDefinition) error {
+	baseimports := []*codegen.ImportSpec{
 		codegen.SimpleImport("github.com/jinzhu/gorm"),
 		codegen.SimpleImport("github.com/jinzhu/copier"),
 		codegen.SimpleImport("time"),
 	}
-	// get the imports for the app packages
-	api.IterateVersions(func(v *design.APIVersionDefinition) error {
-		if v.IsDefault() {
-			return nil
-		}
-		imports = append(imports, codegen.SimpleImport(imp+"/"+codegen.Goify(v.Version, false)))
-		return nil
-	})
-	// Now generate the models, by iterating the versions
-	err = api.IterateVersions(func(v *design.APIVersionDefinition) error {
-		verdir := outdir
-		if v.Version != "" {
-			return nil
-		}
-		if err := os.MkdirAll(verdir, 0755); err != nil {
-			return err
-		}
-		var outPkg string
-		// going to hell for this == HELP Wanted (windows) TODO:(BJK)
-		outPkg = codegen.DesignPackagePath[0:strings.LastIndex(codegen.DesignPackagePath, "/")]
-		if err != nil {
-			panic(err)
-		}
-		outPkg = strings.TrimPrefix(outPkg, "src/")
-
-		_, cached := metaLookup(api.Metadata, "#cached")
-		if cached {
-			imports = append(imports, codegen.SimpleImport("github.com/patrickmn/go-cache"))
-		}
-
-		err = v.IterateUserTypes(func(res *design.UserTypeDefinition) error {
-			if res.Type.IsObject() {
-				title := fmt.Sprintf("%s: Models", api.Name)
-				name := strings.ToLower(deModel(res.TypeName))
-
-				err := os.MkdirAll(filepath.Join(modelDir(), name), 0755)
-				if err != nil {
-					panic(err)
-				}
+	_, cached := metaLookup(api.Metadata, CACHED)
+	if cached {
+		baseimports = append(baseimports, codegen.SimpleImport("github.com/patrickmn/go-cache"))
+	}
+	title := fmt.Sprintf("%s: Models", api.Name)
 
-				filename := filepath.Join(verdir, name, name+"_gen.go")
-				os.Remove(filename)
-				mtw, err := NewModelWriter(filename)
-				if err != nil {
-					panic(err)
+	// Now generate the models, by iterating the versions
+	err := api.IterateVersions(func(v *design.APIVersionDefinition) error {
+		return v.IterateUserTypes(func(res *design.UserTypeDefinition) error {
+			if !res.Type.IsObject() {
+				return nil
+			}
+			md, err := NewModelData(v, res)
+			if err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			isModel := modelMetadata(res.Definition())
+			if isModel {
+				if err := g.modelHooks(&md); err != nil {
+					g.fail(res.TypeName, err)
+					return nil
 				}
+			}
+			name := strings.ToLower(deModel(res.TypeName))
 
-				md := NewModelData(v.Version, res)
-				for k, _ := range md.RequiredPackages {
-					imports = append(imports, codegen.SimpleImport(path.Join(mainimp, "models", k)))
-				}
+			filename := filepath.Join(versionDir(g.OutputDir, md.APIVersion), name, name+"_gen.go")
+			out, err := g.prepareFile(filename)
+			if err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			mtw, err := NewModelWriter(out)
+			if err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			if err := overrideTemplate(mtw.ModelTmpl, g.TemplatesDir); err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			g.genfiles = append(g.genfiles, filename)
 
-				mtw.WriteHeader(title, name, imports)
-				if m, ok := metaLookup(res.Metadata, ""); ok && m == "Model" {
-					err = mtw.Execute(&md)
-					if err != nil {
-						fmt.Println("Error executing Gorma: ", err.Error())
-						g.Cleanup()
-						return err
-					}
-				}
-				if err := mtw.FormatCode(); err != nil {
-					fmt.Println("Error executing Gorma: ", err.Error())
-					g.Cleanup()
+			imports := append([]*codegen.ImportSpec{
+				codegen.SimpleImport(appImportPath(g.AppPackage, md.APIVersion)),
+			}, baseimports...)
+			for _, k := range sortedKeys(md.RequiredPackages) {
+				imports = append(imports, codegen.SimpleImport(modelImportPath(g.ModelsPackage, md.APIVersion, k)))
+			}
+			imports = append(imports, md.Imports...)
 
+			mtw.WriteHeader(title, name, imports)
+			if m, ok := metaLookup(res.Metadata, ""); ok && m == "Model" {
+				if err := mtw.Execute(&md); err != nil {
+					g.fail(res.TypeName, err)
+					return nil
 				}
-				if err == nil {
-					g.genfiles = append(g.genfiles, filename)
-				}
+			}
+			if err := mtw.FormatCode(); err != nil {
+				g.fail(res.TypeName, err)
 				return nil
 			}
-
+			if isModel {
+				if err := g.writePluginFiles(&md, filepath.Dir(filename)); err != nil {
+					g.fail(res.TypeName, err)
+				}
+			}
 			return nil
-
 		})
-		return nil
 	})
 
 	return err
 }
 
+// generateImpls writes the scaffolds extending the generated models. A scaffold
+// is only written when its file doesn't exist so that hand-written code added
+// to it survives regeneration.
+func (g *Generator) generateImpls(api *design.APIDefinition) error {
+	return api.IterateVersions(func(v *design.APIVersionDefinition) error {
+		return v.IterateUserTypes(func(res *design.UserTypeDefinition) error {
+			if !res.Type.IsObject() || !modelMetadata(res.Definition()) {
+				return nil
+			}
+			id := NewImplData(v, res, g.ModelsPackage)
+			filename := filepath.Join(versionDir(g.OutputDir, id.APIVersion), id.ModelLower+".go")
+			if _, err := os.Stat(filename); err == nil {
+				return nil
+			}
+			out, err := g.prepareFile(filename)
+			if err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			iw, err := NewImplWriter(out)
+			if err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			if err := overrideTemplate(iw.ImplTmpl, g.TemplatesDir); err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			g.genfiles = append(g.genfiles, filename)
+			g.scaffolds[filename] = true
+			if err := iw.Execute(&id); err != nil {
+				g.fail(res.TypeName, err)
+				return nil
+			}
+			if err := iw.FormatCode(); err != nil {
+				g.fail(res.TypeName, err)
+			}
+			return nil
+		})
+	})
+}
+
 // Generate produces the generated rbac files
 func (g *Generator) generateRBAC(api *design.APIDefinition) error {
-	err := os.MkdirAll(modelDir(), 0755)
-	if err != nil {
-		panic(err)
-	}
-	app := kingpin.New("Model generator", "model generator")
-	codegen.RegisterFlags(app)
-	_, err = app.Parse(os.Args[1:])
-	if err != nil {
-		panic(err)
-	}
-	var outPkg string
-	// going to hell for this == HELP Wanted (windows) TODO:(BJK)
-	outPkg = codegen.DesignPackagePath[0:strings.LastIndex(codegen.DesignPackagePath, "/")]
-	if err != nil {
-		panic(err)
-	}
-	outPkg = strings.TrimPrefix(outPkg, "src/")
-	appPkg := filepath.Join(outPkg, "app")
-
 	rbacimports := []*codegen.ImportSpec{
-		codegen.SimpleImport(appPkg),
+		codegen.SimpleImport(g.AppPackage),
 		codegen.SimpleImport("github.com/mikespook/gorbac"),
 	}
 
 	rbactitle := fmt.Sprintf("%s: RBAC", api.Name)
-	_, dorbac := metaLookup(api.Metadata, "#rbac")
+	_, dorbac := metaLookup(api.Metadata, RBAC)
 
 	if dorbac {
-		rbacfilename := filepath.Join(modelDir(), "rbac_gen.go")
-		os.Remove(rbacfilename)
-		rbacw, err := NewRbacWriter(rbacfilename)
+		rbacfilename := filepath.Join(modelDir(g.OutputDir), "rbac_gen.go")
+		out, err := g.prepareFile(rbacfilename)
 		if err != nil {
-			fmt.Println("Error executing Gorma: ", err.Error())
-			panic(err)
+			g.fail("", err)
+			return nil
 		}
-		rbacw.WriteHeader(rbactitle, "models", rbacimports)
-		err = rbacw.Execute(api)
+		rbacw, err := NewRbacWriter(out)
 		if err != nil {
-			fmt.Println("Error executing Gorma: ", err.Error())
-			g.Cleanup()
-			return err
+			g.fail("", err)
+			return nil
 		}
-		if err := rbacw.FormatCode(); err != nil {
-			fmt.Println("Error executing Gorma: ", err.Error())
-			g.Cleanup()
-			return err
+		if err := overrideTemplate(rbacw.RbacTmpl, g.TemplatesDir); err != nil {
+			g.fail("", err)
+			return nil
+		}
+		g.genfiles = append(g.genfiles, rbacfilename)
+		rbacw.WriteHeader(rbactitle, "models", rbacimports)
+		if err := rbacw.Execute(api); err != nil {
+			g.fail("", err)
+			return nil
 		}
-		if err == nil {
-			g.genfiles = append(g.genfiles, rbacfilename)
+		if err := rbacw.FormatCode(); err != nil {
+			g.fail("", err)
 		}
 	}
 
-	return err
+	return nil
 }
 
 // Generate produces the generated media files
 func (g *Generator) generateResources(api *design.APIDefinition) error {
-	os.MkdirAll(modelDir(), 0755)
-	app := kingpin.New("Model generator", "model generator")
-	codegen.RegisterFlags(app)
-	_, err := app.Parse(os.Args[1:])
-	if err != nil {
-		panic(err)
-	}
-	gopath := filepath.SplitList(os.Getenv("GOPATH"))[0]
-
-	mainimp, err := filepath.Rel(filepath.Join(gopath, "src"), codegen.OutputDir)
-	if err != nil {
-		return err
-	}
-	mainimp = filepath.ToSlash(mainimp)
-	imp := path.Join(mainimp, "app")
-	imports := []*codegen.ImportSpec{
-		codegen.SimpleImport(imp),
-		codegen.SimpleImport("github.com/jinzhu/copier"),
-	}
-	// get the imports for the app packages
-	api.IterateVersions(func(v *design.APIVersionDefinition) error {
-		if v.IsDefault() {
-			return nil
-		}
-		imports = append(imports, codegen.SimpleImport(filepath.Join(filepath.ToSlash(imp), codegen.Goify(codegen.VersionPackage(v.Version), false))))
-		return nil
-	})
 
 	title := fmt.Sprintf("%s: Media Helpers", api.Name)
 
-	err = api.IterateVersions(func(v *design.APIVersionDefinition) error {
-		err = v.IterateResources(func(res *design.ResourceDefinition) error {
+	err := api.IterateVersions(func(v *design.APIVersionDefinition) error {
+		return v.IterateResources(func(res *design.ResourceDefinition) error {
 			actionable := false
-			err = res.IterateActions(func(ad *design.ActionDefinition) error {
+			res.IterateActions(func(ad *design.ActionDefinition) error {
 				if hasUserType(ad) {
 					actionable = true
 				}
@@ -274,81 +365,60 @@ func (g *Generator) generateResources(api *design.APIDefinition) error {
 			}
 			name := strings.ToLower(codegen.Goify(res.Name, false))
 
-			err := os.MkdirAll(filepath.Join(modelDir(), name), 0755)
-			if err != nil {
-				panic(err)
+			rd := NewResourceData(v, res)
+			if err := g.resourceHooks(&rd); err != nil {
+				g.fail(res.Name, err)
+				return nil
 			}
 
-			mediafilename := filepath.Join(modelDir(), name, name+prefix+"_gen.go")
-			os.Remove(mediafilename)
+			mediafilename := filepath.Join(versionDir(g.OutputDir, rd.APIVersion), name, name+prefix+"_gen.go")
+			out, err := g.prepareFile(mediafilename)
+			if err != nil {
+				g.fail(res.Name, err)
+				return nil
+			}
 
-			resw, err := NewResourceWriter(mediafilename)
+			resw, err := NewResourceWriter(out)
 			if err != nil {
-				fmt.Println("Error executing Gorma: ", err.Error())
-				panic(err)
+				g.fail(res.Name, err)
+				return nil
+			}
+			if err := overrideTemplate(resw.ResourceTmpl, g.TemplatesDir); err != nil {
+				g.fail(res.Name, err)
+				return nil
 			}
+			g.genfiles = append(g.genfiles, mediafilename)
 
-			rd := NewResourceData(v.Version, res)
-			for k, _ := range rd.RequiredPackages {
-				imports = append(imports, codegen.SimpleImport(path.Join(mainimp, "models", k)))
+			imports := []*codegen.ImportSpec{
+				codegen.SimpleImport(appImportPath(g.AppPackage, rd.APIVersion)),
+				codegen.SimpleImport("github.com/jinzhu/copier"),
 			}
+			for _, k := range sortedKeys(rd.RequiredPackages) {
+				imports = append(imports, codegen.SimpleImport(modelImportPath(g.ModelsPackage, rd.APIVersion, k)))
+			}
+			imports = append(imports, rd.Imports...)
 			resw.WriteHeader(title, name, imports)
 
-			err = resw.Execute(&rd)
-			if err != nil {
-				fmt.Println("Error executing Gorma: ", err.Error())
-				g.Cleanup()
-				return err
+			if err := resw.Execute(&rd); err != nil {
+				g.fail(res.Name, err)
+				return nil
 			}
 			if err := resw.FormatCode(); err != nil {
-				fmt.Println("Error executing Gorma: ", err.Error())
-				g.Cleanup()
-				return err
-			}
-			if err == nil {
-				g.genfiles = append(g.genfiles, mediafilename)
+				g.fail(res.Name, err)
 			}
 			return nil
 		})
-		return nil
 	})
 	return err
 }
 
 // Generate produces the generated media files
 func (g *Generator) generateMedia(api *design.APIDefinition) error {
-	os.MkdirAll(modelDir(), 0755)
-	app := kingpin.New("Model generator", "model generator")
-	codegen.RegisterFlags(app)
-	_, err := app.Parse(os.Args[1:])
-	if err != nil {
-		panic(err)
-	}
-	gopath := filepath.SplitList(os.Getenv("GOPATH"))[0]
-
-	mainimp, err := filepath.Rel(filepath.Join(gopath, "src"), codegen.OutputDir)
-	if err != nil {
-		return err
-	}
-	mainimp = filepath.ToSlash(mainimp)
-	imp := path.Join(mainimp, "app")
-	imports := []*codegen.ImportSpec{
-		codegen.SimpleImport(imp),
-		codegen.SimpleImport("github.com/jinzhu/copier"),
-	}
-	// get the imports for the app packages
-	api.IterateVersions(func(v *design.APIVersionDefinition) error {
-		if v.IsDefault() {
-			return nil
-		}
-		imports = append(imports, codegen.SimpleImport(imp+"/"+codegen.Goify(codegen.VersionPackage(v.Version), false)))
-		return nil
-	})
 
 	title := fmt.Sprintf("%s: Media Helpers", api.Name)
 
-	err = api.IterateVersions(func(v *design.APIVersionDefinition) error {
-		err = v.IterateMediaTypes(func(res *design.MediaTypeDefinition) error {
+	err := api.IterateVersions(func(v *design.APIVersionDefinition) error {
+		return v.IterateMediaTypes(func(res *design.MediaTypeDefinition) error {
 			if res.Reference == nil {
 				// not a mediatype that references a model
 				return nil
@@ -370,45 +440,51 @@ func (g *Generator) generateMedia(api *design.APIDefinition) error {
 				}
 				name := strings.ToLower(codegen.Goify(res.TypeName, false))
 
-				err := os.MkdirAll(filepath.Join(modelDir(), name), 0755)
-				if err != nil {
-					panic(err)
+				md := NewMediaData(v, res)
+				if err := g.mediaHooks(&md); err != nil {
+					g.fail(res.TypeName, err)
+					return nil
 				}
 
-				mediafilename := filepath.Join(modelDir(), name, name+prefix+"_gen.go")
+				mediafilename := filepath.Join(versionDir(g.OutputDir, md.APIVersion), name, name+prefix+"_gen.go")
+				out, err := g.prepareFile(mediafilename)
+				if err != nil {
+					g.fail(res.TypeName, err)
+					return nil
+				}
 
-				os.Remove(mediafilename)
-				resw, err := NewMediaWriter(mediafilename)
+				resw, err := NewMediaWriter(out)
 				if err != nil {
-					fmt.Println("Error executing Gorma: ", err.Error())
-					panic(err)
+					g.fail(res.TypeName, err)
+					return nil
+				}
+				if err := overrideTemplate(resw.MediaTmpl, g.TemplatesDir); err != nil {
+					g.fail(res.TypeName, err)
+					return nil
 				}
+				g.genfiles = append(g.genfiles, mediafilename)
 
-				md := NewMediaData(v.Version, res)
-				for k, _ := range md.RequiredPackages {
-					imports = append(imports, codegen.SimpleImport(path.Join(mainimp, "models", k)))
+				imports := []*codegen.ImportSpec{
+					codegen.SimpleImport(appImportPath(g.AppPackage, md.APIVersion)),
+					codegen.SimpleImport("github.com/jinzhu/copier"),
+				}
+				for _, k := range sortedKeys(md.RequiredPackages) {
+					imports = append(imports, codegen.SimpleImport(modelImportPath(g.ModelsPackage, md.APIVersion, k)))
 				}
+				imports = append(imports, md.Imports...)
 				resw.WriteHeader(title, name, imports)
 
-				err = resw.Execute(&md)
-				if err != nil {
-					fmt.Println("Error executing Gorma: ", err.Error())
-					g.Cleanup()
-					return err
+				if err := resw.Execute(&md); err != nil {
+					g.fail(res.TypeName, err)
+					return nil
 				}
 				if err := resw.FormatCode(); err != nil {
-					fmt.Println("Error executing Gorma: ", err.Error())
-					g.Cleanup()
-					return err
-				}
-				if err == nil {
-					g.genfiles = append(g.genfiles, mediafilename)
+					g.fail(res.TypeName, err)
 				}
 			}
 			return nil
 
 		})
-		return err
 	})
 	return err
 }
@@ -416,7 +492,7 @@ func (g *Generator) generateMedia(api *design.APIDefinition) error {
 // Cleanup removes all the files generated by this generator during the last invokation of Generate.
 func (g *Generator) Cleanup() {
 	for _, f := range g.genfiles {
-		os.Remove(f)
+		os.Remove(g.target(f))
 	}
 	g.genfiles = nil
 }
@@ -3,8 +3,10 @@ package gorma
 import (
 	"bytes"
 	"fmt"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -25,8 +27,81 @@ const (
 	DYNAMICTABLE = "#dyntablename"
 	MEDIA        = "#nomedia"
 	CACHE        = "#cache"
+	PKTYPE       = "#pktype"
+	GORMTAG      = "#gormtag"
+	SQLTAG       = "#sqltag"
+	GORMPKTAG    = "#gormpktag"
+	SKIPTS       = "#skipts"
+	AUTHBOSS     = "#authboss"
+	RBAC         = "#rbac"
+	CACHED       = "#cached"
+	INDEX        = "#index"
+	UNIQUE       = "#unique"
+	INDEXES      = "#indexes"
+	NOVALIDATE   = "#novalidate"
+	VERSIONED    = "#versioned"
+	TIMESTAMPS   = "#timestamps"
+	SOFTDELETE   = "#softdelete"
+	POLYMORPHIC  = "#polymorphic"
+	EAGER        = "#eager"
 )
 
+// metaScope is the set of design definitions a gorma metadata key applies to.
+type metaScope int
+
+const (
+	apiScope metaScope = 1 << iota
+	modelScope
+	attributeScope
+	mediaScope
+)
+
+// String returns the name of the definitions in the scope.
+func (s metaScope) String() string {
+	var names []string
+	for _, n := range []struct {
+		scope metaScope
+		name  string
+	}{{apiScope, "the API"}, {modelScope, "models"}, {attributeScope, "attributes"}, {mediaScope, "media types and resources"}} {
+		if s&n.scope != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// metaKeys is the registry of the supported gorma metadata keys and their
+// scopes. The empty key is the "Model" tag itself.
+var metaKeys = map[string]metaScope{
+	"":           modelScope,
+	M2M:          modelScope,
+	BELONGSTO:    modelScope | mediaScope,
+	HASONE:       modelScope,
+	HASMANY:      modelScope,
+	ROLER:        modelScope,
+	TABLENAME:    modelScope,
+	DYNAMICTABLE: modelScope,
+	MEDIA:        modelScope | mediaScope,
+	CACHE:        modelScope,
+	PKTYPE:       modelScope | attributeScope,
+	GORMTAG:      attributeScope,
+	SQLTAG:       attributeScope,
+	GORMPKTAG:    modelScope,
+	SKIPTS:       modelScope,
+	AUTHBOSS:     modelScope,
+	RBAC:         apiScope,
+	CACHED:       apiScope,
+	INDEX:        attributeScope,
+	UNIQUE:       attributeScope,
+	INDEXES:      modelScope,
+	NOVALIDATE:   modelScope,
+	VERSIONED:    modelScope,
+	TIMESTAMPS:   modelScope,
+	SOFTDELETE:   modelScope,
+	POLYMORPHIC:  modelScope,
+	EAGER:        modelScope,
+}
+
 func versionize(s string) string {
 	if s == "app" {
 		return "Default"
@@ -84,6 +159,48 @@ func GetAttributeColumns(att *design.AttributeDefinition) []Field {
 	return columns
 }
 
+// sqlTag returns the content of the sql tag of the field generated for the
+// attribute called name of the object def: the value of its "#sqltag" metadata
+// followed by the index and constraint settings it doesn't already contain.
+func sqlTag(def *design.AttributeDefinition, name string) string {
+	att := def.Type.ToObject()[name]
+	var settings []string
+	val, _ := metaLookup(att.Metadata, SQLTAG)
+	if val != "" {
+		settings = append(settings, val)
+	}
+	for _, t := range append(indexTags(att), constraintTags(def, name)...) {
+		name := strings.SplitN(t, ":", 2)[0]
+		if !hasSetting(val, name) && !(name == "size" && hasSetting(val, "type")) {
+			settings = append(settings, t)
+		}
+	}
+	return strings.Join(settings, ";")
+}
+
+// hasSetting returns true if the tag content holds the given setting.
+func hasSetting(tag, name string) bool {
+	for _, s := range strings.Split(tag, ";") {
+		if strings.EqualFold(strings.TrimSpace(strings.SplitN(s, ":", 2)[0]), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// modelColumns returns the columns of a model the storage finders are
+// generated for, enum columns have the enum type.
+func modelColumns(md *ModelData) []Field {
+	columns := GetAttributeColumns(md.TypeDef.AttributeDefinition)
+	for i, c := range columns {
+		if e, ok := findEnum(md.Enums, c.Column); ok {
+			columns[i].Coltype = e.TypeName
+			columns[i].Enum = true
+		}
+	}
+	return columns
+}
+
 // camelToSnake converts a given string to snake case.
 func camelToSnake(s string) string {
 	var result string
@@ -123,8 +240,46 @@ func camelToSnake(s string) string {
 }
 
 // modelDir is the path to the directory where the schema controller is generated.
-func modelDir() string {
-	return filepath.Join(codegen.OutputDir, "models")
+func modelDir(outDir string) string {
+	return filepath.Join(outDir, "models")
+}
+
+// versionDir is the path to the directory where the models of the given API
+// version package are generated. Models of the default version ("app") live
+// directly under modelDir.
+func versionDir(outDir, apiVersion string) string {
+	if apiVersion == "app" {
+		return modelDir(outDir)
+	}
+	return filepath.Join(modelDir(outDir), codegen.Goify(apiVersion, false))
+}
+
+// appImportPath returns the import path of the goa app package generated for
+// the given API version package.
+func appImportPath(appimp, apiVersion string) string {
+	if apiVersion == "app" {
+		return appimp
+	}
+	return path.Join(appimp, codegen.Goify(apiVersion, false))
+}
+
+// modelImportPath returns the import path of the model package called name
+// generated for the given API version package.
+func modelImportPath(modelsimp, apiVersion, name string) string {
+	if apiVersion == "app" {
+		return path.Join(modelsimp, name)
+	}
+	return path.Join(modelsimp, codegen.Goify(apiVersion, false), name)
+}
+
+// sortedKeys returns the keys of a set in sort order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // deModel removes the word "Model" from the string.
@@ -145,14 +300,18 @@ func upper(s string) string {
 // metaLookup is a helper function to lookup gorma-namespaced metadata keys in a
 // case-insensitive way.
 func metaLookup(md design.MetadataDefinition, hashtag string) (result string, ok bool) {
+	if v, found := md[META_NAMESPACE+hashtag]; found {
+		return v, true
+	}
+	// keys are case insensitive, use the first matching key in sort order so
+	// that the lookup is deterministic
 	needle := strings.ToLower(META_NAMESPACE + hashtag)
+	var match string
 	for k, v := range md {
-		k = strings.ToLower(k)
-		if k == needle {
-			return v, true
+		if strings.ToLower(k) == needle && (!ok || k < match) {
+			match, result, ok = k, v, true
 		}
 	}
-
 	return
 }
 
@@ -164,31 +323,47 @@ func metaLookupTmpl(md design.MetadataDefinition, hashtag string) string {
 
 // StorageDef creates the storage interface that will be used
 // in place of a concrete type for testability.
-func StorageDef(res *design.UserTypeDefinition) string {
+func StorageDef(md *ModelData) string {
 	var associations string
-	if assoc, ok := metaLookup(res.Metadata, "#many2many"); ok {
-		children := strings.Split(assoc, ",")
-
-		for _, child := range children {
-			pieces := strings.Split(child, ":")
-			associations = associations + "List" + pieces[0] + "(context.Context, int) []" + lower(pieces[1]) + "." + pieces[1] + "\n"
-			associations = associations + "Add" + pieces[1] + "(context.Context, int, int) (error)\n"
-			associations = associations + "Delete" + pieces[1] + "(context.Context, int, int) error \n"
+	for _, m2m := range md.M2M {
+		associations = associations + "List" + m2m.PluralRelation + "(context.Context, " + md.PKType + ") []" + m2m.LowerRelation + "." + m2m.Relation + "\n"
+		if m2m.Join != "" {
+			join := m2m.LowerJoin + "." + m2m.Join
+			associations = associations + "List" + m2m.PluralRelation + "WithJoin(context.Context, " + md.PKType + ") []" + m2m.Relation + "WithJoin\n"
+			associations = associations + "Add" + m2m.Relation + "(context.Context, " + md.PKType + ", " + m2m.KeyType + ", " + join + ") (error)\n"
+			associations = associations + "Update" + m2m.Relation + "(context.Context, " + md.PKType + ", " + m2m.KeyType + ", " + join + ") (error)\n"
+		} else {
+			associations = associations + "Add" + m2m.Relation + "(context.Context, " + md.PKType + ", " + m2m.KeyType + ") (error)\n"
 		}
+		associations = associations + "Delete" + m2m.Relation + "(context.Context, " + md.PKType + ", " + m2m.KeyType + ") error \n"
 	}
 	return associations
 }
 
 // includeForeignKey adds foreign key relations to the struct being
-// generated.
-func includeForeignKey(res *design.AttributeDefinition) string {
+// generated, followed by the fields holding the parents.
+func includeForeignKey(md *ModelData) string {
 	var associations string
-	if assoc, ok := metaLookup(res.Metadata, "#belongsto"); ok {
-		children := strings.Split(assoc, ",")
-
-		for _, child := range children {
-			associations = associations + child + "ID int\n"
-
+	for _, bt := range md.BelongsTo {
+		typ := bt.KeyType
+		if bt.Nullable {
+			typ = "*" + typ
+		}
+		associations = associations + bt.Role + "ID " + typ
+		if bt.Column != camelToSnake(bt.Role+"ID") {
+			associations = associations + " `gorm:\"column:" + bt.Column + "\"`"
+		}
+		associations = associations + "\n"
+		if bt.ParentField != "" {
+			typ := lower(bt.Parent) + "." + bt.Parent
+			if bt.Parent == md.TypeName {
+				typ = "*" + bt.Parent
+			}
+			tag := "ForeignKey:" + bt.Role + "ID"
+			if bt.ParentKey != "ID" {
+				tag += ";AssociationForeignKey:" + bt.ParentKey
+			}
+			associations = associations + bt.ParentField + " " + typ + " `gorm:\"" + tag + "\"`\n"
 		}
 	}
 	return associations
@@ -201,44 +376,24 @@ func plural(s string) string {
 
 // includeChildren adds the fields to a struct represented
 // in a has-many relationship.
-func includeChildren(res *design.AttributeDefinition) string {
-	var associations string
-	if assoc, ok := metaLookup(res.Metadata, "#hasmany"); ok {
-		children := strings.Split(assoc, ",")
-
-		for _, child := range children {
-			associations = associations + inflection.Plural(child) + " []" + lower(child) + "." + child + "\n"
-		}
-	}
-	if assoc, ok := metaLookup(res.Metadata, "#hasone"); ok {
-		children := strings.Split(assoc, ",")
-		for _, child := range children {
-			associations = associations + child + " " + lower(child) + "." + child + "\n"
-			associations = associations + child + "ID " + "*sql.NullInt64\n"
-		}
-	}
-	return associations
+func includeChildren(md *ModelData) string {
+	return childFields(md)
 }
 
 // includeMany2Many returns the appropriate struct tags
 // for a m2m relationship in gorm.
-func includeMany2Many(res *design.AttributeDefinition) string {
+func includeMany2Many(md *ModelData) string {
 	var associations string
-	if assoc, ok := metaLookup(res.Metadata, "#many2many"); ok {
-		children := strings.Split(assoc, ",")
-
-		for _, child := range children {
-			pieces := strings.Split(child, ":")
-			associations = associations + pieces[0] + "\t []" + lower(deModel(pieces[1])) + "." + pieces[1] + "\t" + "`gorm:\"many2many:" + pieces[2] + ";\"`\n"
-		}
+	for _, m2m := range md.M2M {
+		associations = associations + m2m.PluralRelation + "\t []" + lower(deModel(m2m.Relation)) + "." + m2m.Relation + "\t" + "`gorm:\"many2many:" + m2m.TableName + ";\"`\n"
 	}
 	return associations
 }
 
 // includeAuthboss returns the tags required to implement authboss storage.
 // Currently experimental and quite unfinished.
-func includeAuthboss(res *design.AttributeDefinition) string {
-	if _, ok := metaLookup(res.Metadata, "#authboss"); ok {
+func includeAuthboss(md *ModelData) string {
+	if _, ok := metaLookup(md.TypeDef.Metadata, AUTHBOSS); ok {
 		fields := `	// Auth
 	Password string
 
@@ -272,19 +427,49 @@ func split(s string, sep string) []string {
 	return strings.Split(s, sep)
 }
 
-// includeTimeStamps returns the timestamp fields if "skipts" isn't set.
-func includeTimeStamps(res *design.AttributeDefinition) string {
+// includeTimeStamps returns the CreatedAt and UpdatedAt fields of the models
+// with timestamps and the DeletedAt field of the soft deletable ones.
+func includeTimeStamps(md *ModelData) string {
 	var ts string
-	if _, ok := metaLookup(res.Metadata, "#skipts"); ok {
-		ts = ""
-	} else {
-		ts = "CreatedAt time.Time\nUpdatedAt time.Time\nDeletedAt *time.Time\n"
+	if md.DoTimestamps {
+		ts = "CreatedAt time.Time\nUpdatedAt time.Time\n"
+	}
+	if md.DoSoftDelete {
+		ts += "DeletedAt *time.Time\n"
 	}
 	return ts
 }
 
+// metaSwitch returns the value of a metadata tag turning a feature on or off:
+// a missing tag returns def, an empty value or "true" turns it on and "false"
+// off.
+func metaSwitch(utd *design.UserTypeDefinition, key string, def bool) (bool, error) {
+	val, ok := metaLookup(utd.Metadata, key)
+	if !ok {
+		return def, nil
+	}
+	if val == "" {
+		return true, nil
+	}
+	on, err := strconv.ParseBool(val)
+	if err != nil {
+		return def, &GenerationError{TypeName: utd.TypeName, Key: key, Err: fmt.Errorf("invalid value %q, expected true or false", val)}
+	}
+	return on, nil
+}
+
+// includeVersion returns the version field of the models with the
+// "versioned" tag.
+func includeVersion(md *ModelData) string {
+	if !md.DoVersioned {
+		return ""
+	}
+	return "Version int `json:\"version\" sql:\"not null\"`\n"
+}
+
 // ModelDef is the main function to create a struct definition.
-func ModelDef(res *design.UserTypeDefinition) string {
+func ModelDef(md *ModelData) (string, error) {
+	res := md.TypeDef
 	var buffer bytes.Buffer
 	def := res.Definition()
 	t := def.Type
@@ -303,10 +488,19 @@ func ModelDef(res *design.UserTypeDefinition) string {
 		for _, name := range keys {
 			codegen.WriteTabs(&buffer, 1)
 			// func GoTypeDef(ds design.DataStructure, versioned bool, defPkg string, tabs int, jsonTags, inner bool) string {
-			typedef := codegen.GoTypeDef(actual[name], false, "app", 1, true)
+			typedef := codegen.GoTypeDef(actual[name], false, md.APIVersion, 1, true)
 			if actual[name].Type.IsObject() || def.IsPrimitivePointer(name) {
 				typedef = "*" + typedef
 			}
+			if e, ok := findEnum(md.Enums, name); ok {
+				typedef = e.TypeName
+				if e.Pointer {
+					typedef = "*" + typedef
+				}
+			}
+			if pk, ok := findPrimaryKey(md.PrimaryKeys, name); ok {
+				typedef = pk.Type
+			}
 			fname := codegen.Goify(name, true)
 			var tags string
 			var omit string
@@ -314,13 +508,13 @@ func ModelDef(res *design.UserTypeDefinition) string {
 			if !def.IsRequired(name) {
 				omit = ",omitempty"
 			}
-			if val, ok := metaLookup(actual[name].Metadata, "#gormtag"); ok {
+			if val, ok := metaLookup(actual[name].Metadata, GORMTAG); ok {
 				gorm = fmt.Sprintf(" gorm:\"%s\"", val)
 				if strings.Contains(gorm, "primary_key") {
 					typedef = strings.Replace(typedef, "*", "", -1)
 				}
 			}
-			if val, ok := metaLookup(actual[name].Metadata, "#sqltag"); ok {
+			if val := sqlTag(def, name); val != "" {
 				sql = fmt.Sprintf(" sql:\"%s\"", val)
 			}
 			tags = fmt.Sprintf(" `json:\"%s%s\"%s%s`", name, omit, gorm, sql)
@@ -331,106 +525,202 @@ func ModelDef(res *design.UserTypeDefinition) string {
 			buffer.WriteString(fmt.Sprintf("%s%s %s%s\n", desc, fname, typedef, tags))
 		}
 
-		for k, v := range genfuncs {
-			s := v(def)
+		for _, gf := range append(genfuncs, md.Sections...) {
+			s := gf.Fields(md)
 			if s != "" {
-				buffer.WriteString(fmt.Sprintf("%s%s", k, s))
+				buffer.WriteString(fmt.Sprintf("%s%s", gf.Comment, s))
 			}
 		}
 
 		codegen.WriteTabs(&buffer, 0)
 		buffer.WriteString("}")
-		return buffer.String()
+		return buffer.String(), nil
 	default:
-		panic("gorma bug: unexpected data structure type")
+		return "", &GenerationError{TypeName: res.TypeName, Err: fmt.Errorf("model must be an object, got %s", t.Name())}
 	}
 }
 
+// PrimaryKey describes one of the primary key fields of a model.
 type PrimaryKey struct {
-	Field string
-	Type  string
-}
-
-func getPrimaryKeys(res *design.UserTypeDefinition) map[string]PrimaryKey {
-	pks := make(map[string]PrimaryKey, 0)
+	Field  string
+	Column string
+	Type   string
+	// Package is the import path of the package of Type, empty for builtin
+	// types.
+	Package string
+}
+
+// getPrimaryKeys returns the primary keys of the model sorted by attribute
+// name, the order in which they appear in the generated struct.
+func getPrimaryKeys(res *design.UserTypeDefinition) ([]PrimaryKey, error) {
+	var pks []PrimaryKey
 	def := res.Definition()
 	t := def.Type
-	fmt.Println(res.TypeName)
 	switch actual := t.(type) {
 	case design.Object:
+		names := make([]string, 0, len(actual))
 		for n := range actual {
-			typ := "int"
-
-			if gt, ok := metaLookup(actual[n].Metadata, "#gormtag"); ok {
-				if strings.Contains(gt, "primary_key") {
-					pk := PrimaryKey{
-						Field: n,
-						Type:  typ, // TODO(BJK) support others
-					}
-					fmt.Println("added pk from gormtag")
-					pks[n] = pk
-				}
-			}
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			field := n
 			if n == "ID" || n == "Id" || n == "id" {
-				pk := PrimaryKey{
-					Field: n,
-					Type:  typ, //TODO (BJK) support others
-				}
-				pks[n] = pk
-				fmt.Println("added pk from ID definition")
+				field = "id"
+			} else if gt, ok := metaLookup(actual[n].Metadata, GORMTAG); !ok || !strings.Contains(gt, "primary_key") {
+				continue
 			}
+			if _, ok := findPrimaryKey(pks, field); ok {
+				continue
+			}
+			typ, pkg, err := pkType(actual[n])
+			if err != nil {
+				return nil, &GenerationError{TypeName: res.TypeName, Attribute: n, Key: PKTYPE, Err: err}
+			}
+			pks = append(pks, PrimaryKey{
+				Field:   field,
+				Column:  camelToSnake(codegen.Goify(field, true)),
+				Type:    typ,
+				Package: pkg,
+			})
 		}
 
 	default:
-		panic("gorma bug: expected data structure type")
+		return nil, &GenerationError{TypeName: res.TypeName, Err: fmt.Errorf("model must be an object, got %s", t.Name())}
 	}
 	if len(pks) == 0 {
-		pks["id"] = PrimaryKey{Field: "id", Type: "int"}
+		pk := PrimaryKey{Field: "id", Column: "id", Type: "int"}
+		if val, ok := metaLookup(res.Metadata, PKTYPE); ok {
+			var err error
+			if pk.Type, pk.Package, err = parseKeyType(val); err != nil {
+				return nil, &GenerationError{TypeName: res.TypeName, Key: PKTYPE, Err: err}
+			}
+		}
+		pks = append(pks, pk)
 	}
-	return pks
+	return pks, nil
 }
 
-func pkAttributes(pks map[string]PrimaryKey) string {
-	var pkdefs []string
+// findPrimaryKey returns the primary key for the attribute called field.
+func findPrimaryKey(pks []PrimaryKey, field string) (PrimaryKey, bool) {
 	for _, pk := range pks {
-		def := fmt.Sprintf("%s %s", pk.Field, pk.Type)
-		pkdefs = append(pkdefs, def)
+		if pk.Field == field {
+			return pk, true
+		}
 	}
+	return PrimaryKey{}, false
+}
 
-	return strings.Join(pkdefs, ",")
+// pkType returns the Go type of a primary key attribute and the import path
+// of its package. The #pktype metadata wins over the type derived from the goa
+// attribute type.
+func pkType(att *design.AttributeDefinition) (typ, pkg string, err error) {
+	if val, ok := metaLookup(att.Metadata, PKTYPE); ok {
+		return parseKeyType(val)
+	}
+	switch att.Type.Kind() {
+	case design.IntegerKind:
+		return "int", "", nil
+	case design.StringKind:
+		return "string", "", nil
+	default:
+		return codegen.GoNativeType(att.Type), "", nil
+	}
 }
-func pkWhere(pks map[string]PrimaryKey) string {
 
-	var pkwhere []string
-	for _, pk := range pks {
-		def := fmt.Sprintf("%s = ?", pk.Field)
-		pkwhere = append(pkwhere, def)
+// lookupModel returns the gorma model called name (as it appears in the
+// relationship metadata) defined in the given API version, or nil.
+func lookupModel(v *design.APIVersionDefinition, name string) *design.UserTypeDefinition {
+	var found *design.UserTypeDefinition
+	v.IterateUserTypes(func(utd *design.UserTypeDefinition) error {
+		if found == nil && modelMetadata(utd.Definition()) && deModel(codegen.GoTypeName(utd, 0)) == deModel(name) {
+			found = utd
+		}
+		return nil
+	})
+	return found
+}
+
+// modelKey returns the primary key of the gorma model called name. Unknown
+// models and models with a compound primary key get the conventional "id int",
+// validateRelations reports the relations referencing them.
+func modelKey(v *design.APIVersionDefinition, name string) PrimaryKey {
+	if utd := lookupModel(v, name); utd != nil && utd.Type.IsObject() {
+		if pks, err := getPrimaryKeys(utd); err == nil && len(pks) == 1 {
+			return pks[0]
+		}
 	}
+	return PrimaryKey{Field: "id", Type: "int"}
+}
+
+// keyIsSet returns the Go expression testing whether the key variable
+// name of type typ holds a non zero value.
+func keyIsSet(typ, name string) string {
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return name + " > 0"
+	case "string":
+		return name + ` != ""`
+	default:
+		return fmt.Sprintf("%s != (%s{})", name, typ)
+	}
+}
+
+// pkAttributes returns the parameter declaration of the model key used by
+// the storage methods: the primary key itself or the compound key struct.
+func pkAttributes(md *ModelData) string {
+	return fmt.Sprintf("%s %s", pkName(md), md.PKType)
+}
 
-	pkw := strings.Join(pkwhere, " and ")
-	return pkw
+// pkName returns the name of the key parameter declared by pkAttributes.
+func pkName(md *ModelData) string {
+	if len(md.PrimaryKeys) > 1 {
+		return "key"
+	}
+	return md.PrimaryKeys[0].Field
 }
-func pkWhereFields(pks map[string]PrimaryKey) string {
 
+// pkWhere returns the SQL condition selecting a single model by key.
+func pkWhere(md *ModelData) string {
 	var pkwhere []string
-	for _, pk := range pks {
-		def := fmt.Sprintf("%s", pk.Field)
-		pkwhere = append(pkwhere, def)
+	for _, pk := range md.PrimaryKeys {
+		pkwhere = append(pkwhere, fmt.Sprintf("%s = ?", pk.Column))
 	}
+	return strings.Join(pkwhere, " and ")
+}
 
-	pkw := strings.Join(pkwhere, ",")
-	return pkw
+// pkWhereFields returns the arguments matching the placeholders of pkWhere.
+func pkWhereFields(md *ModelData) string {
+	if len(md.PrimaryKeys) == 1 {
+		return pkName(md)
+	}
+	var fields []string
+	for _, pk := range md.PrimaryKeys {
+		fields = append(fields, fmt.Sprintf("key.%s", codegen.Goify(pk.Field, true)))
+	}
+	return strings.Join(fields, ", ")
 }
-func pkUpdateFields(pks map[string]PrimaryKey) string {
 
-	var pkwhere []string
-	for _, pk := range pks {
-		def := fmt.Sprintf("model.%s", codegen.Goify(pk.Field, true))
-		pkwhere = append(pkwhere, def)
+// pkUpdateFields returns the key of the model held in the "model" variable.
+func pkUpdateFields(md *ModelData) string {
+	if len(md.PrimaryKeys) > 1 {
+		return "model.Key()"
 	}
+	return fmt.Sprintf("model.%s", md.PKField)
+}
 
-	pkw := strings.Join(pkwhere, ",")
-	return pkw
+// pkAssign returns the statements setting the key fields of target from
+// the key held in value.
+func pkAssign(md *ModelData, target, value string) string {
+	if len(md.PrimaryKeys) == 1 {
+		return fmt.Sprintf("%s.%s = %s", target, md.PKField, value)
+	}
+	var stmts []string
+	for _, pk := range md.PrimaryKeys {
+		f := codegen.Goify(pk.Field, true)
+		stmts = append(stmts, fmt.Sprintf("%s.%s = %s.%s", target, f, value, f))
+	}
+	return strings.Join(stmts, "\n")
 }
 
 // setupIDAttribute adds or updates the ID field of a user type definition.
@@ -440,7 +730,7 @@ func setupIDAttribute(obj design.Object, res *design.UserTypeDefinition) design.
 	foundPK := false
 	count := 0
 	for n := range obj {
-		if gt, ok := metaLookup(obj[n].Metadata, "#gormtag"); ok {
+		if gt, ok := metaLookup(obj[n].Metadata, GORMTAG); ok {
 			if strings.Contains(gt, "primary_key") {
 				count = count + 1
 				foundPK = true
@@ -472,15 +762,21 @@ func setupIDAttribute(obj design.Object, res *design.UserTypeDefinition) design.
 		}
 
 		var gorm string
-		if val, ok := metaLookup(res.Metadata, "#gormpktag"); ok {
+		if val, ok := metaLookup(res.Metadata, GORMPKTAG); ok {
 			gorm = val
 		} else {
 			gorm = "primary_key"
 		}
 
 		// If the user already defined gormtag, leave it alone.
-		if _, ok := metaLookup(obj["id"].Metadata, "#gormtag"); !ok {
-			obj["id"].Metadata[META_NAMESPACE+"#gormtag"] = gorm
+		if _, ok := metaLookup(obj["id"].Metadata, GORMTAG); !ok {
+			obj["id"].Metadata[META_NAMESPACE+GORMTAG] = gorm
+		}
+
+		// Keep the key type of the model, getPrimaryKeys reads it from the
+		// attribute once it is added.
+		if val, ok := metaLookup(res.Metadata, PKTYPE); ok {
+			obj["id"].Metadata[META_NAMESPACE+PKTYPE] = val
 		}
 	}
 
@@ -514,16 +810,26 @@ func startsWithInitialism(s string) string {
 	return initialism
 }
 
-// genfuncs is a map of comments and functions that will be used by ModelDef
-// to conditionally add fields to the model struct.  If the function returns
-// content, the content will be preceded by the the map key, which should be a
-// comment.
-var genfuncs = map[string]func(*design.AttributeDefinition) string{
-	"\n// Timestamps\n":   includeTimeStamps,
-	"\n// Many2Many\n":    includeMany2Many,
-	"\n// Foreign Keys\n": includeForeignKey,
-	"\n// Children\n":     includeChildren,
-	"\n// Authboss\n\n":   includeAuthboss,
+// ModelSection is a group of fields conditionally added to a model struct.
+type ModelSection struct {
+	// Comment precedes the fields, e.g. "\n// Timestamps\n".
+	Comment string
+	// Fields returns the fields of the model, one per line, or the empty
+	// string to omit the section.
+	Fields func(*ModelData) string
+}
+
+// genfuncs is the ordered list of sections that will be used by ModelDef to
+// conditionally add fields to the model struct, followed by the sections of
+// the model data.
+var genfuncs = []ModelSection{
+	{"\n// Timestamps\n", includeTimeStamps},
+	{"\n// Optimistic locking\n", includeVersion},
+	{"\n// Many2Many\n", includeMany2Many},
+	{"\n// Foreign Keys\n", includeForeignKey},
+	{"\n// Polymorphic Keys\n", includePolymorphic},
+	{"\n// Children\n", includeChildren},
+	{"\n// Authboss\n\n", includeAuthboss},
 }
 
 // commonInitialisms, taken from
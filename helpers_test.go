@@ -0,0 +1,234 @@
+package gorma
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestImportPaths(t *testing.T) {
+	cases := []struct {
+		version string
+		dir     string
+		app     string
+		model   string
+	}{
+		{"app", filepath.Join("out", "models"), "example.com/app/app", "example.com/app/models/user"},
+		{"v1", filepath.Join("out", "models", "v1"), "example.com/app/app/v1", "example.com/app/models/v1/user"},
+	}
+	for _, c := range cases {
+		if got := versionDir("out", c.version); got != c.dir {
+			t.Errorf("%s: got directory %q want %q", c.version, got, c.dir)
+		}
+		if got := appImportPath("example.com/app/app", c.version); got != c.app {
+			t.Errorf("%s: got app import path %q want %q", c.version, got, c.app)
+		}
+		if got := modelImportPath("example.com/app/models", c.version, "user"); got != c.model {
+			t.Errorf("%s: got model import path %q want %q", c.version, got, c.model)
+		}
+	}
+}
+
+func TestPkType(t *testing.T) {
+	pktype := func(val string) *design.AttributeDefinition {
+		return &design.AttributeDefinition{Type: design.String, Metadata: design.MetadataDefinition{META_NAMESPACE + PKTYPE: val}}
+	}
+	cases := []struct {
+		name string
+		att  *design.AttributeDefinition
+		typ  string
+		pkg  string
+		err  bool
+	}{
+		{name: "integer", att: &design.AttributeDefinition{Type: design.Integer}, typ: "int"},
+		{name: "string", att: &design.AttributeDefinition{Type: design.String}, typ: "string"},
+		{name: "number", att: &design.AttributeDefinition{Type: design.Number}, typ: "float64"},
+		{name: "pktype", att: pktype("int64"), typ: "int64"},
+		{name: "package type", att: pktype("github.com/google/uuid.UUID"), typ: "uuid.UUID", pkg: "github.com/google/uuid"},
+		{name: "unknown pktype", att: pktype("integer"), err: true},
+	}
+	for _, c := range cases {
+		typ, pkg, err := pkType(c.att)
+		if (err != nil) != c.err || typ != c.typ || pkg != c.pkg {
+			t.Errorf("%s: got %q, %q, %v want %q, %q", c.name, typ, pkg, err, c.typ, c.pkg)
+		}
+	}
+}
+
+func TestKeyIsSet(t *testing.T) {
+	cases := []struct {
+		typ  string
+		want string
+	}{
+		{"int", "id > 0"},
+		{"uint64", "id > 0"},
+		{"string", `id != ""`},
+		{"uuid.UUID", "id != (uuid.UUID{})"},
+	}
+	for _, c := range cases {
+		if got := keyIsSet(c.typ, "id"); got != c.want {
+			t.Errorf("%s: got %q want %q", c.typ, got, c.want)
+		}
+	}
+}
+
+func TestGetPrimaryKeys(t *testing.T) {
+	pk := design.MetadataDefinition{META_NAMESPACE + "#gormtag": "primary_key"}
+	cases := []struct {
+		name string
+		obj  design.Object
+		want []PrimaryKey
+		err  string
+	}{
+		{
+			name: "id",
+			obj:  design.Object{"id": &design.AttributeDefinition{Type: design.Integer}, "name": &design.AttributeDefinition{Type: design.String}},
+			want: []PrimaryKey{{Field: "id", Column: "id", Type: "int"}},
+		},
+		{
+			name: "compound",
+			obj: design.Object{
+				"user_id": &design.AttributeDefinition{Type: design.Integer, Metadata: pk},
+				"role_id": &design.AttributeDefinition{Type: design.String, Metadata: pk},
+				"name":    &design.AttributeDefinition{Type: design.String},
+			},
+			want: []PrimaryKey{{Field: "role_id", Column: "role_id", Type: "string"}, {Field: "user_id", Column: "user_id", Type: "int"}},
+		},
+		{
+			name: "not an object",
+			err:  "type UserRole: model must be an object, got string",
+		},
+	}
+	for _, c := range cases {
+		var typ design.DataType = design.String
+		if c.obj != nil {
+			typ = c.obj
+		}
+		utd := &design.UserTypeDefinition{AttributeDefinition: &design.AttributeDefinition{Type: typ}, TypeName: "UserRole"}
+		got, err := getPrimaryKeys(utd)
+		if err != nil {
+			if err.Error() != c.err {
+				t.Errorf("%s: got error %q want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if c.err != "" {
+			t.Errorf("%s: got %+v want error %q", c.name, got, c.err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPkStatements(t *testing.T) {
+	cases := []struct {
+		name   string
+		md     *ModelData
+		where  string
+		assign string
+	}{
+		{
+			name:   "single",
+			md:     &ModelData{PrimaryKeys: []PrimaryKey{{Field: "id", Column: "id", Type: "int"}}, PKField: "ID"},
+			where:  "id = ?",
+			assign: "obj.ID = id",
+		},
+		{
+			name: "compound",
+			md: &ModelData{PrimaryKeys: []PrimaryKey{
+				{Field: "role_id", Column: "role_id", Type: "int"},
+				{Field: "user_id", Column: "user_id", Type: "int"},
+			}},
+			where:  "role_id = ? and user_id = ?",
+			assign: "obj.RoleID = key.RoleID\nobj.UserID = key.UserID",
+		},
+	}
+	for _, c := range cases {
+		if got := pkWhere(c.md); got != c.where {
+			t.Errorf("%s: got where %q want %q", c.name, got, c.where)
+		}
+		value := pkName(c.md)
+		if got := pkAssign(c.md, "obj", value); got != c.assign {
+			t.Errorf("%s: got assignment %q want %q", c.name, got, c.assign)
+		}
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	cases := []struct {
+		set  map[string]bool
+		want []string
+	}{
+		{map[string]bool{}, []string{}},
+		{map[string]bool{"user": true, "account": true, "proposal": true}, []string{"account", "proposal", "user"}},
+	}
+	for _, c := range cases {
+		if got := sortedKeys(c.set); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %v want %v", c.set, got, c.want)
+		}
+	}
+}
+
+func TestMetaLookup(t *testing.T) {
+	cases := []struct {
+		name string
+		md   design.MetadataDefinition
+		want string
+		ok   bool
+	}{
+		{"missing", design.MetadataDefinition{META_NAMESPACE + HASMANY: "Proposal"}, "", false},
+		{"exact", design.MetadataDefinition{META_NAMESPACE + "#belongsTo": "Account", META_NAMESPACE + BELONGSTO: "User"}, "User", true},
+		{
+			"case insensitive",
+			design.MetadataDefinition{META_NAMESPACE + "#belongsTo": "User", META_NAMESPACE + "#BelongsTo": "Account"},
+			"Account",
+			true,
+		},
+	}
+	for _, c := range cases {
+		if got, ok := metaLookup(c.md, BELONGSTO); got != c.want || ok != c.ok {
+			t.Errorf("%s: got %q, %v want %q, %v", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestSetupIDAttribute(t *testing.T) {
+	cases := []struct {
+		name string
+		meta map[string]string
+		obj  design.Object
+		want PrimaryKey
+	}{
+		{
+			name: "added id",
+			obj:  design.Object{"name": &design.AttributeDefinition{Type: design.String}},
+			want: PrimaryKey{Field: "id", Column: "id", Type: "int"},
+		},
+		{
+			name: "added id with pktype",
+			meta: map[string]string{PKTYPE: "int64"},
+			obj:  design.Object{"name": &design.AttributeDefinition{Type: design.String}},
+			want: PrimaryKey{Field: "id", Column: "id", Type: "int64"},
+		},
+		{
+			name: "renamed id",
+			obj:  design.Object{"ID": &design.AttributeDefinition{Type: design.String}},
+			want: PrimaryKey{Field: "id", Column: "id", Type: "string"},
+		},
+	}
+	for _, c := range cases {
+		utd := newTestModel("User", c.meta)
+		utd.Type = setupIDAttribute(c.obj, utd)
+		pks, err := getPrimaryKeys(utd)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if len(pks) != 1 || pks[0] != c.want {
+			t.Errorf("%s: got %+v want %+v", c.name, pks, c.want)
+		}
+	}
+}
@@ -1,33 +1,37 @@
 package gorma
 
-const implTmpl = `// {{if .TypeDef.Description}}{{.TypeDef.Description}}{{else}}app.{{ .TypeName}} model type{{end}}
-// Identifier: {{ .TypeName}}
-{{ $dynamictable := .DoDynamicTableName }}
-{{ $typename  := .TypeName }}
-{{ $typedef := .TypeDef  }}
-{{ $pks := .PrimaryKeys }}
-type {{$typename}}Storage interface {
-	DB() interface{}
-	List(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}}
-	One(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ pkattributes $pks  }}) ({{$typename}}, error)
-	Add(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, o {{$typename}}) ({{$typename}}, error)
-	Update(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, o {{$typename}}) (error)
-	Delete(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ pkattributes $pks }}) (error)
-{{ range $idx, $bt := .BelongsTo}}
-	ListBy{{$bt.Parent}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid int) []{{$typename}}
-	OneBy{{$bt.Parent}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid, id int) ({{$typename}}, error)
-{{end}}
-	{{ storagedef $typedef }}
-}
+const implTmpl = `// This file extends the generated {{.ModelLower}} package.
+// It is only created when missing, add your own code here: gorma never
+// overwrites it.
 
-func New{{.TypeName}}DB(db gorm.DB) *{{.TypeName}}DB {
-	return &{{.TypeName}}DB{ {{.ModelLower}}.{{.TypeName}}DB{Db: db} }
+package {{.Package}}
 
-}
+import (
+	"github.com/jinzhu/gorm"
+	"{{.ModelPackage}}"
+)
+
+// {{.TypeName}} wraps the generated {{.ModelLower}}.{{.TypeName}} model.
 type {{.TypeName}} struct {
 	{{.ModelLower}}.{{.TypeName}}
 }
+
+// {{.TypeName}}Storage extends the generated storage interface.
+type {{.TypeName}}Storage interface {
+	{{.ModelLower}}.{{.TypeName}}Storage
+}
+
+// {{.TypeName}}DB wraps the generated {{.ModelLower}}.{{.TypeName}}DB, add
+// your own methods to it.
 type {{.TypeName}}DB struct {
-	{{.ModelLower}}.{{.TypeName}}DB
+	*{{.ModelLower}}.{{.TypeName}}DB
 }
+
+// New{{.TypeName}}DB returns a {{.TypeName}}DB using the given database.
+func New{{.TypeName}}DB(db gorm.DB) *{{.TypeName}}DB {
+	return &{{.TypeName}}DB{ {{.ModelLower}}.New{{.TypeName}}DB(db) }
+}
+
+var _ {{.TypeName}}Storage = (*{{.TypeName}}DB)(nil)
+{{ block "extra" . }}{{ end }}
 `
@@ -1,127 +1,54 @@
 package gorma
 
 import (
-	"strings"
 	"text/template"
 
 	"github.com/raphael/goa/design"
 	"github.com/raphael/goa/goagen/codegen"
 )
 
-// ImplWriter generate code for a goa application media types.
-// Media types are data structures used to render the response bodies.
+// ImplWriter generates the scaffold of the hand-written extensions of a model.
 type ImplWriter struct {
 	*codegen.GoGenerator
 	ImplTmpl *template.Template
 }
+
+// ImplData is the data used to render the scaffold of a model extension.
 type ImplData struct {
-	TypeDef            *design.UserTypeDefinition
-	TypeName           string
-	ModelUpper         string
-	ModelLower         string
-	BelongsTo          []BelongsTo
-	M2M                []Many2Many
-	PrimaryKeys        map[string]PrimaryKey
-	CustomTableName    string
-	DoMedia            bool
-	DoRoler            bool
-	DoCustomTableName  bool
-	DoDynamicTableName bool
-	DoCache            bool
-	APIVersion         string
-	RequiredPackages   map[string]bool
+	TypeDef    *design.UserTypeDefinition
+	TypeName   string
+	ModelUpper string
+	ModelLower string
+	// Package is the name of the package the scaffold belongs to.
+	Package string
+	// ModelPackage is the import path of the generated model package.
+	ModelPackage string
+	APIVersion   string
 }
 
-func NewImplData(version string, utd *design.UserTypeDefinition) ImplData {
+// NewImplData returns the data used to render the scaffold extending the
+// model generated for the given user type. modelsimp is the import path of
+// the models directory.
+func NewImplData(v *design.APIVersionDefinition, utd *design.UserTypeDefinition, modelsimp string) ImplData {
 	md := ImplData{
-		TypeDef:          utd,
-		RequiredPackages: make(map[string]bool, 0),
+		TypeDef: utd,
 	}
 	tn := deModel(codegen.GoTypeName(utd, 0))
 	md.TypeName = tn
 	md.ModelUpper = upper(tn)
 	md.ModelLower = lower(tn)
-	if version != "" {
-		md.APIVersion = codegen.VersionPackage(version)
+	if v.Version != "" {
+		md.APIVersion = codegen.VersionPackage(v.Version)
+		md.Package = codegen.Goify(md.APIVersion, false)
 	} else {
 		md.APIVersion = "app"
+		md.Package = "models"
 	}
-	md.PrimaryKeys = getPrimaryKeys(utd)
-
-	var belongs []BelongsTo
-	if bt, ok := metaLookup(utd.Metadata, BELONGSTO); ok {
-		btlist := strings.Split(bt, ",")
-		for _, s := range btlist {
-			binst := BelongsTo{
-				Parent:        s,
-				DatabaseField: camelToSnake(s),
-			}
-			belongs = append(belongs, binst)
-		}
-	}
-	md.BelongsTo = belongs
-
-	var m2m []Many2Many
-	if m2, ok := metaLookup(utd.Metadata, M2M); ok {
-		mlist := strings.Split(m2, ",")
-		for _, s := range mlist {
-			parms := strings.Split(s, ":")
-			if len(parms) == 3 {
-
-				minst := Many2Many{
-					Relation:            parms[1],
-					LowerRelation:       lower(parms[1]),
-					PluralRelation:      parms[0],
-					LowerPluralRelation: lower(parms[0]),
-					TableName:           parms[2],
-				}
-				m2m = append(m2m, minst)
-
-				md.RequiredPackages[lower(deModel(parms[1]))] = true
-			}
-		}
-
-	}
-	md.M2M = m2m
-
-	if many, ok := metaLookup(utd.Metadata, "#hasmany"); ok {
-		list := strings.Split(many, ",")
-		for _, s := range list {
-			md.RequiredPackages[lower(s)] = true
-		}
-	}
-
-	if children, ok := metaLookup(utd.Metadata, "#hasone"); ok {
-		list := strings.Split(children, ",")
-		for _, s := range list {
-			md.RequiredPackages[lower(s)] = true
-		}
-	}
-
-	if _, ok := metaLookup(utd.Metadata, ROLER); ok {
-		md.DoRoler = ok
-	}
-
-	if ctn, ok := metaLookup(utd.Metadata, TABLENAME); ok {
-		md.CustomTableName = ctn
-		md.DoCustomTableName = ok
-	}
-
-	if _, ok := metaLookup(utd.Metadata, DYNAMICTABLE); ok {
-		md.DoDynamicTableName = ok
-	}
-	md.DoMedia = true
-	if _, ok := metaLookup(utd.Metadata, MEDIA); ok {
-		md.DoMedia = !ok
-	}
-	if _, ok := metaLookup(utd.Metadata, CACHE); ok {
-		md.DoCache = ok
-	}
+	md.ModelPackage = modelImportPath(modelsimp, md.APIVersion, md.ModelLower)
 	return md
 }
 
-// NewImplWriter returns a contexts code writer.
-// Media types contain the data used to render response bodies.
+// NewImplWriter returns a model extension scaffold writer.
 func NewImplWriter(filename string) (*ImplWriter, error) {
 	cw := codegen.NewGoGenerator(filename)
 	funcMap := cw.FuncMap
@@ -148,7 +75,7 @@ func NewImplWriter(filename string) (*ImplWriter, error) {
 	funcMap["pkwhere"] = pkWhere
 	funcMap["pkwherefields"] = pkWhereFields
 	funcMap["pkupdatefields"] = pkUpdateFields
-	implTmpl, err := template.New("implementations").Funcs(funcMap).Parse(implTmpl)
+	implTmpl, err := template.New("impl").Funcs(funcMap).Parse(implTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +86,7 @@ func NewImplWriter(filename string) (*ImplWriter, error) {
 	return &w, nil
 }
 
-// Execute writes the code for the context types to the writer.
+// Execute writes the scaffold to the writer.
 func (w *ImplWriter) Execute(md *ImplData) error {
 	return w.ImplTmpl.Execute(w, md)
 }
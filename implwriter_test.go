@@ -0,0 +1,57 @@
+package gorma
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestGenerateImpls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mine := "package models\n\n// Mine is hand-written.\nfunc Mine() {}\n"
+	writeTestFile(t, dir, "models/user.go", mine)
+	api := &design.APIDefinition{}
+	api.Types = map[string]*design.UserTypeDefinition{"UserModel": newTestModel("User", nil)}
+	v1 := &design.APIVersionDefinition{Version: "v1", Types: map[string]*design.UserTypeDefinition{"UserModel": newTestModel("User", nil)}}
+	api.APIVersions = map[string]*design.APIVersionDefinition{"v1": v1}
+	g := &Generator{Options: Options{OutputDir: dir, ModelsPackage: "example.com/app/models"}, scaffolds: make(map[string]bool)}
+	if err := g.generateImpls(api); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.errs) > 0 {
+		t.Fatal(g.errs)
+	}
+	scaffold := filepath.Join(dir, "models", "v1", "user.go")
+	if want := []string{scaffold}; !reflect.DeepEqual(g.genfiles, want) {
+		t.Errorf("got files %v want %v", g.genfiles, want)
+	}
+	if !g.scaffolds[scaffold] {
+		t.Errorf("%s is not recorded as a scaffold", scaffold)
+	}
+	cases := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"existing scaffold", "models/user.go", mine},
+		{"new scaffold", "models/v1/user.go", "package v1"},
+	}
+	for _, c := range cases {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(c.path)))
+		switch {
+		case err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case !strings.Contains(string(b), c.want):
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, b, c.want)
+		}
+	}
+}
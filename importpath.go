@@ -0,0 +1,86 @@
+package gorma
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importPath returns the Go import path of the package in directory dir. The
+// path is derived from the module declared in the nearest go.mod found in dir
+// or one of its parents. When dir isn't part of a module the path is computed
+// relative to the src directory of the GOPATH entry containing dir.
+func importPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.Clean(abs)
+	for root := abs; ; {
+		modfile := filepath.Join(root, "go.mod")
+		if _, err := os.Stat(modfile); err == nil {
+			mod, err := modulePath(modfile)
+			if err != nil {
+				return "", err
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(mod, filepath.ToSlash(rel)), nil
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			break
+		}
+		root = parent
+	}
+	for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
+		src, err := filepath.Abs(filepath.Join(gopath, "src"))
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(src, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		return filepath.ToSlash(rel), nil
+	}
+	return "", fmt.Errorf("cannot compute the import path of %s: no go.mod found and not in GOPATH", dir)
+}
+
+// modulePath returns the module path declared by the module directive of a
+// go.mod file.
+func modulePath(modfile string) (string, error) {
+	f, err := os.Open(modfile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		mod := fields[1]
+		if strings.HasPrefix(mod, `"`) || strings.HasPrefix(mod, "`") {
+			if mod, err = strconv.Unquote(mod); err != nil {
+				return "", fmt.Errorf("%s: invalid module path %s", modfile, fields[1])
+			}
+		}
+		return mod, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: missing module directive", modfile)
+}
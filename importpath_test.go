@@ -0,0 +1,80 @@
+package gorma
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFile writes content to the file name of dir, creating the missing
+// directories.
+func writeTestFile(t *testing.T, dir, name, content string) {
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cases := []struct {
+		name    string
+		content string
+		want    string
+		err     bool
+	}{
+		{"plain", "module example.com/app\n\ngo 1.12\n", "example.com/app", false},
+		{"comment", "// module example.com/old\nmodule example.com/app // the app\n", "example.com/app", false},
+		{"quoted", "module \"example.com/app\"\n", "example.com/app", false},
+		{"raw quoted", "module `example.com/app`\n", "example.com/app", false},
+		{"bad quotes", "module \"example.com/app\n", "", true},
+		{"missing", "go 1.12\n", "", true},
+	}
+	for _, c := range cases {
+		writeTestFile(t, dir, "go.mod", c.content)
+		got, err := modulePath(filepath.Join(dir, "go.mod"))
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("%s: got %q, %v want %q", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", filepath.Join(dir, "gopath"))
+	writeTestFile(t, dir, "mod/go.mod", "module example.com/app // the app\n")
+	writeTestFile(t, dir, "mod/models/user/user.go", "package user\n")
+	writeTestFile(t, dir, "gopath/src/example.com/legacy/models/user.go", "package models\n")
+	writeTestFile(t, dir, "elsewhere/models/user.go", "package models\n")
+	cases := []struct {
+		name string
+		dir  string
+		want string
+		err  bool
+	}{
+		{"module root", "mod", "example.com/app", false},
+		{"nested package", "mod/models/user", "example.com/app/models/user", false},
+		{"gopath", "gopath/src/example.com/legacy/models", "example.com/legacy/models", false},
+		{"gopath src", "gopath/src", "", true},
+		{"no go.mod", "elsewhere/models", "", true},
+	}
+	for _, c := range cases {
+		got, err := importPath(filepath.Join(dir, c.dir))
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("%s: got %q, %v want %q", c.name, got, err, c.want)
+		}
+	}
+}
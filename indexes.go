@@ -0,0 +1,133 @@
+package gorma
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// Index is an index declared with the "#indexes" metadata of a model.
+type Index struct {
+	// Name is the name of the index.
+	Name string
+	// Unique is true for unique indexes.
+	Unique bool
+	// Columns lists the indexed columns in index order.
+	Columns []string
+}
+
+// Unique is a set of columns with a unique constraint, a OneBy finder is
+// generated for each of them.
+type Unique struct {
+	// Name is the suffix of the name of the finder, e.g. "Email" for
+	// OneByEmail.
+	Name string
+	// Columns lists the columns of the constraint.
+	Columns []Field
+}
+
+// getIndexes parses the "#indexes" metadata of a model. Its value lists the
+// indexes separated by semicolons, each made of an optional "unique" keyword,
+// the name of the index, a colon and the comma separated list of the indexed
+// attributes, e.g. "idx_name:last_name,first_name;unique uq_email:org_id,email".
+func getIndexes(utd *design.UserTypeDefinition) ([]Index, error) {
+	val, ok := metaLookup(utd.Metadata, INDEXES)
+	if !ok {
+		return nil, nil
+	}
+	obj := utd.Type.ToObject()
+	fail := func(format string, a ...interface{}) error {
+		return &GenerationError{TypeName: utd.TypeName, Key: INDEXES, Err: fmt.Errorf(format, a...)}
+	}
+	var indexes []Index
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(val, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var idx Index
+		if fields := strings.Fields(entry); len(fields) > 1 && fields[0] == "unique" {
+			idx.Unique = true
+			entry = strings.TrimSpace(strings.TrimPrefix(entry, "unique"))
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fail("malformed index %q, expected [unique ]name:attribute,...", entry)
+		}
+		idx.Name = strings.TrimSpace(parts[0])
+		if names[idx.Name] {
+			return nil, fail("duplicate index name %q", idx.Name)
+		}
+		names[idx.Name] = true
+		for _, c := range strings.Split(parts[1], ",") {
+			c = strings.TrimSpace(c)
+			if _, ok := obj[c]; !ok {
+				return nil, fail("index %q references unknown attribute %q", idx.Name, c)
+			}
+			idx.Columns = append(idx.Columns, c)
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+// getUniques returns the unique constraints of a model: the attributes
+// tagged with "#unique" followed by the unique indexes.
+func getUniques(md *ModelData) []Unique {
+	columns := make(map[string]Field)
+	for _, c := range modelColumns(md) {
+		columns[c.Column] = c
+	}
+	var uniques []Unique
+	md.TypeDef.Type.ToObject().IterateAttributes(func(n string, att *design.AttributeDefinition) error {
+		if _, ok := metaLookup(att.Metadata, UNIQUE); ok {
+			uniques = append(uniques, Unique{
+				Name:    codegen.Goify(n, true),
+				Columns: []Field{columns[n]},
+			})
+		}
+		return nil
+	})
+	for _, idx := range md.Indexes {
+		if !idx.Unique {
+			continue
+		}
+		u := Unique{}
+		for _, c := range idx.Columns {
+			u.Name += codegen.Goify(c, true)
+			u.Columns = append(u.Columns, columns[c])
+		}
+		uniques = append(uniques, u)
+	}
+	// the same columns may be both tagged and indexed
+	var deduped []Unique
+	seen := make(map[string]bool)
+	for _, u := range uniques {
+		if !seen[u.Name] {
+			seen[u.Name] = true
+			deduped = append(deduped, u)
+		}
+	}
+	return deduped
+}
+
+// indexTags returns the sql tag settings of the "#index" and "#unique"
+// metadata of an attribute. A tag value other than "true" names the index.
+func indexTags(att *design.AttributeDefinition) []string {
+	var tags []string
+	for _, t := range []struct{ key, setting string }{{INDEX, "index"}, {UNIQUE, "unique_index"}} {
+		val, ok := metaLookup(att.Metadata, t.key)
+		if !ok {
+			continue
+		}
+		if val != "" && val != "true" {
+			tags = append(tags, t.setting+":"+val)
+		} else {
+			tags = append(tags, t.setting)
+		}
+	}
+	return tags
+}
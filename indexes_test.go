@@ -0,0 +1,86 @@
+package gorma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestGetIndexes(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  []Index
+		err   string
+	}{
+		{
+			name:  "single",
+			value: "idx_name:last_name,first_name",
+			want:  []Index{{Name: "idx_name", Columns: []string{"last_name", "first_name"}}},
+		},
+		{
+			name:  "unique and spaces",
+			value: " idx_name : last_name ; unique  uq_email:org_id, email ;",
+			want: []Index{
+				{Name: "idx_name", Columns: []string{"last_name"}},
+				{Name: "uq_email", Unique: true, Columns: []string{"org_id", "email"}},
+			},
+		},
+		{
+			name:  "index named unique",
+			value: "unique:email",
+			want:  []Index{{Name: "unique", Columns: []string{"email"}}},
+		},
+		{name: "missing colon", value: "idx_name", err: `malformed index "idx_name"`},
+		{name: "empty name", value: ":email", err: `malformed index ":email"`},
+		{name: "unique without name", value: "unique :email", err: `malformed index ":email"`},
+		{name: "duplicate name", value: "idx:email;idx:org_id", err: `duplicate index name "idx"`},
+		{name: "unknown attribute", value: "idx:email,age", err: `index "idx" references unknown attribute "age"`},
+		{name: "trailing comma", value: "idx:email,", err: `index "idx" references unknown attribute ""`},
+	}
+	for _, c := range cases {
+		utd := newTestModel("User", map[string]string{INDEXES: c.value})
+		utd.Type = design.Object{
+			"email":      &design.AttributeDefinition{Type: design.String},
+			"org_id":     &design.AttributeDefinition{Type: design.Integer},
+			"first_name": &design.AttributeDefinition{Type: design.String},
+			"last_name":  &design.AttributeDefinition{Type: design.String},
+		}
+		got, err := getIndexes(utd)
+		switch {
+		case c.err != "":
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case !reflect.DeepEqual(got, c.want):
+			t.Errorf("%s: got %+v want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIndexTags(t *testing.T) {
+	cases := []struct {
+		meta map[string]string
+		want []string
+	}{
+		{nil, nil},
+		{map[string]string{INDEX: ""}, []string{"index"}},
+		{map[string]string{INDEX: "true"}, []string{"index"}},
+		{map[string]string{INDEX: "idx_email"}, []string{"index:idx_email"}},
+		{map[string]string{UNIQUE: "true"}, []string{"unique_index"}},
+		{map[string]string{INDEX: "idx_email", UNIQUE: "uq_email"}, []string{"index:idx_email", "unique_index:uq_email"}},
+	}
+	for _, c := range cases {
+		md := design.MetadataDefinition{}
+		for k, v := range c.meta {
+			md[META_NAMESPACE+k] = v
+		}
+		if got := indexTags(&design.AttributeDefinition{Type: design.String, Metadata: md}); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %v want %v", c.meta, got, c.want)
+		}
+	}
+}
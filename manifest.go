@@ -0,0 +1,117 @@
+package gorma
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestName is the name of the file listing the files generated by the
+// last run, relative to the models directory.
+const manifestName = ".gorma-manifest"
+
+// manifestPath returns the path to the manifest file.
+func (g *Generator) manifestPath() string {
+	return filepath.Join(modelDir(g.OutputDir), manifestName)
+}
+
+// readManifest returns the paths listed in the manifest of the previous run,
+// relative to the output directory. A missing manifest lists no file.
+func (g *Generator) readManifest() ([]string, error) {
+	f, err := os.Open(g.manifestPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, line)
+	}
+	return files, scanner.Err()
+}
+
+// writeManifest records the files generated by this run. Scaffolds are not
+// recorded: they belong to the user once written.
+func (g *Generator) writeManifest() error {
+	var files []string
+	for _, f := range g.genfiles {
+		if g.scaffolds[f] {
+			continue
+		}
+		rel, err := filepath.Rel(g.OutputDir, f)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	sort.Strings(files)
+	var buf bytes.Buffer
+	buf.WriteString("# Files generated by gorma, do not edit.\n")
+	for _, f := range files {
+		fmt.Fprintln(&buf, f)
+	}
+	if err := os.MkdirAll(filepath.Dir(g.manifestPath()), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(g.manifestPath(), buf.Bytes(), 0644)
+}
+
+// staleFiles returns the files listed in the manifest of the previous run that
+// this run didn't generate and that still exist. Entries pointing outside of
+// the models directory are ignored.
+func (g *Generator) staleFiles() ([]string, error) {
+	listed, err := g.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]bool, len(g.genfiles))
+	for _, f := range g.genfiles {
+		current[filepath.Clean(f)] = true
+	}
+	models := modelDir(g.OutputDir)
+	var stale []string
+	for _, l := range listed {
+		f := filepath.Join(g.OutputDir, filepath.FromSlash(l))
+		if rel, err := filepath.Rel(models, f); err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if current[f] {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			continue
+		}
+		stale = append(stale, f)
+	}
+	return stale, nil
+}
+
+// prune deletes the stale files along with the directories left empty by
+// their removal.
+func (g *Generator) prune(stale []string) error {
+	models := modelDir(g.OutputDir)
+	for _, f := range stale {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for dir := filepath.Dir(f); dir != models && strings.HasPrefix(dir, models); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package gorma
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	g := &Generator{Options: Options{OutputDir: dir}}
+	if files, err := g.readManifest(); err != nil || files != nil {
+		t.Errorf("missing manifest: got %v, %v", files, err)
+	}
+	scaffold := filepath.Join(dir, "models", "user.go")
+	g.genfiles = []string{
+		filepath.Join(dir, "models", "user", "user_gen.go"),
+		scaffold,
+		filepath.Join(dir, "models", "account", "account_gen.go"),
+	}
+	g.scaffolds = map[string]bool{scaffold: true}
+	if err := g.writeManifest(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"models/account/account_gen.go", "models/user/user_gen.go"}
+	if files, err := g.readManifest(); err != nil || !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v, %v want %v", files, err, want)
+	}
+}
+
+func TestStaleFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	g := &Generator{Options: Options{OutputDir: filepath.Join(dir, "out")}}
+	if stale, err := g.staleFiles(); err != nil || stale != nil {
+		t.Errorf("missing manifest: got %v, %v", stale, err)
+	}
+	for _, f := range []string{
+		"out/models/user/user_gen.go",
+		"out/models/old/old_gen.go",
+		"out/models/v1/old/old_gen.go",
+		"out/app/contexts.go",
+		"x.go",
+	} {
+		writeTestFile(t, dir, f, "package x\n")
+	}
+	writeTestFile(t, dir, "out/models/"+manifestName, `# Files generated by gorma, do not edit.
+models/user/user_gen.go
+  models/old/old_gen.go
+
+models/v1/old/old_gen.go
+models/gone/gone_gen.go
+app/contexts.go
+models/../app/contexts.go
+../x.go
+`)
+	g.genfiles = []string{filepath.Join(dir, "out", "models", "user", "user_gen.go")}
+	stale, err := g.staleFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "out", "models", "old", "old_gen.go"),
+		filepath.Join(dir, "out", "models", "v1", "old", "old_gen.go"),
+	}
+	if !reflect.DeepEqual(stale, want) {
+		t.Errorf("got %v want %v", stale, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{
+		"models/user/user_gen.go",
+		"models/user/mine.go",
+		"models/old/old_gen.go",
+		"models/v1/old/old_gen.go",
+		"models/root_gen.go",
+	} {
+		writeTestFile(t, dir, f, "package x\n")
+	}
+	g := &Generator{Options: Options{OutputDir: dir}}
+	var stale []string
+	for _, f := range []string{"models/user/user_gen.go", "models/old/old_gen.go", "models/v1/old/old_gen.go", "models/root_gen.go", "models/gone/gone_gen.go"} {
+		stale = append(stale, filepath.Join(dir, filepath.FromSlash(f)))
+	}
+	if err := g.prune(stale); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path   string
+		exists bool
+	}{
+		{"models/user/user_gen.go", false},
+		{"models/user/mine.go", true},
+		{"models/user", true},
+		{"models/old", false},
+		{"models/v1", false},
+		{"models/root_gen.go", false},
+		{"models", true},
+	}
+	for _, c := range cases {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(c.path)))
+		if exists := err == nil; exists != c.exists {
+			t.Errorf("%s: exists %v want %v", c.path, exists, c.exists)
+		}
+	}
+}
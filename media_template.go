@@ -6,7 +6,9 @@ const mediaTmpl = `
 func (m {{$typename}}) To{{version .APIVersion}}() *{{.APIVersion}}.{{$typename}} {
 	target := {{.APIVersion}}.{{$typename}}{}
 	copier.Copy(&target, &m)
+	{{ enumconv .Enums .TypeDef.AttributeDefinition "target" "m" false }}
 	return &target
 }
 {{ end }}
+{{ block "extra" . }}{{ end }}
 `
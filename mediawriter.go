@@ -1,8 +1,6 @@
 package gorma
 
 import (
-	"fmt"
-	"strings"
 	"text/template"
 
 	"github.com/raphael/goa/design"
@@ -15,46 +13,52 @@ type MediaWriter struct {
 	*codegen.GoGenerator
 	MediaTmpl *template.Template
 }
+
+// MediaData is the data the media template is rendered with.
 type MediaData struct {
-	TypeDef          *design.MediaTypeDefinition
-	TypeName         string
-	MediaUpper       string
-	MediaLower       string
-	BelongsTo        []BelongsTo
-	DoMedia          bool
-	APIVersion       string
+	// TypeDef is the media type the helpers are generated for.
+	TypeDef *design.MediaTypeDefinition
+	// TypeName is the name of the model the media type renders.
+	TypeName   string
+	MediaUpper string
+	MediaLower string
+	BelongsTo  []BelongsTo
+	// DoMedia is false when the model is tagged with "#nomedia".
+	DoMedia bool
+	// APIVersion is the name of the goa app package of the API version.
+	APIVersion string
+	// Enums lists the enum types of the model.
+	Enums []Enum
+	// RequiredPackages lists the model packages the helpers import.
 	RequiredPackages map[string]bool
+	// Imports lists additional imports of the generated file.
+	Imports []*codegen.ImportSpec
 }
 
-func NewMediaData(version string, utd *design.MediaTypeDefinition) MediaData {
+// NewMediaData returns the data used to render the helpers generated for the
+// given media type.
+func NewMediaData(v *design.APIVersionDefinition, utd *design.MediaTypeDefinition) MediaData {
 	md := MediaData{
 		TypeDef:          utd,
 		RequiredPackages: make(map[string]bool, 0),
 	}
 	md.TypeName = codegen.Goify(utd.TypeName, true)
+	if ref, ok := utd.Reference.(*design.UserTypeDefinition); ok {
+		md.Enums = getEnums(deModel(codegen.GoTypeName(ref, 0)), ref.Definition())
+	}
 	md.MediaUpper = upper(utd.Name())
 	md.MediaLower = lower(utd.Name())
-	if version != "" {
-		md.APIVersion = codegen.VersionPackage(version)
+	if v.Version != "" {
+		md.APIVersion = codegen.VersionPackage(v.Version)
 	} else {
 		// import the default package instead of nothing
 		md.APIVersion = "app"
 	}
 
-	var belongs []BelongsTo
-	if bt, ok := metaLookup(utd.Metadata, BELONGSTO); ok {
-		btlist := strings.Split(bt, ",")
-		for _, s := range btlist {
-			binst := BelongsTo{
-				Parent:        s,
-				DatabaseField: camelToSnake(s),
-			}
-			belongs = append(belongs, binst)
-
-			md.RequiredPackages[lower(s)] = true
-		}
+	md.BelongsTo = helperBelongsTo(v, utd.TypeName, utd.Metadata)
+	for _, bt := range md.BelongsTo {
+		md.RequiredPackages[lower(bt.Parent)] = true
 	}
-	md.BelongsTo = belongs
 	md.DoMedia = true
 	if _, ok := metaLookup(utd.Metadata, MEDIA); ok {
 		md.DoMedia = !ok
@@ -88,6 +92,7 @@ func NewMediaWriter(filename string) (*MediaWriter, error) {
 	funcMap["metaLookup"] = metaLookupTmpl
 	funcMap["columns"] = GetAttributeColumns
 	funcMap["version"] = versionize
+	funcMap["enumconv"] = enumConversions
 
 	modelTmpl, err := template.New("media").Funcs(funcMap).Parse(mediaTmpl)
 	if err != nil {
@@ -102,9 +107,5 @@ func NewMediaWriter(filename string) (*MediaWriter, error) {
 
 // Execute writes the code for the context types to the writer.
 func (w *MediaWriter) Execute(md *MediaData) error {
-	err := w.MediaTmpl.Execute(w, md)
-	if err != nil {
-		fmt.Println("Error executing template", err.Error())
-	}
-	return err
+	return w.MediaTmpl.Execute(w, md)
 }
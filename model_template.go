@@ -1,46 +1,109 @@
 package gorma
 
-const modelTmpl = `// {{if .TypeDef.Description}}{{.TypeDef.Description}}{{else}}app.{{ .TypeName}} storage type{{end}}
+const modelTmpl = `// {{if .TypeDef.Description}}{{.TypeDef.Description}}{{else}}{{.APIVersion}}.{{ .TypeName}} storage type{{end}}
 // Identifier: {{ .TypeName}}
-type {{.TypeName}} {{ modeldef .TypeDef }}
-{{ $typedef := .TypeDef  }}
-{{ $dynamictable := .DoDynamicTableName }}
+type {{.TypeName}} {{ modeldef . }}
+{{ range .Enums }}{{ template "enum" . }}{{ end }}{{ $dynamictable := .DoDynamicTableName }}
 {{ $typename  := .TypeName }}
 {{ $cached := .DoCache }}
 {{ $pks := .PrimaryKeys }}
+{{ $pktype := .PKType }}
 {{ if .DoCustomTableName }}
 func (m {{$typename}}) TableName() string {
 	return "{{ .CustomTableName}}"
 }
 {{ end }}
+{{ if gt (len $pks) 1 }}
+// {{$typename}}Key is the compound primary key of a {{$typename}}.
+type {{$typename}}Key struct {
+{{ range $pk := $pks }}	{{goify $pk.Field true}} {{$pk.Type}}
+{{ end }}}
+
+// Key returns the compound primary key of the {{$typename}}.
+func (m {{$typename}}) Key() {{$typename}}Key {
+	return {{$typename}}Key{
+{{ range $pk := $pks }}		{{goify $pk.Field true}}: m.{{goify $pk.Field true}},
+{{ end }}	}
+}
+{{ end }}
 {{ if .DoRoler }}
 func (m {{$typename}}) GetRole() string {
 	return *m.Role
 }
 {{end}}
+{{ if .DoVersioned }}
+// ErrVersionConflict is returned by Update when the {{$typename}} was changed
+// by another writer since it was read.
+var ErrVersionConflict = errors.New("{{lower $typename}}: version conflict")
+{{ end }}
+// ValidationError describes a field failing the validations of the design.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+// ValidationErrors lists the fields of a model failing validation.
+type ValidationErrors []ValidationError
+
+// Error returns the failures separated by semicolons.
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
 
-type {{$typename}}Storage interface {
+// Validate checks the {{$typename}} against the validations of the design, it
+// returns a ValidationErrors listing the invalid fields.
+func (m {{$typename}}) Validate() error {
+	var errs ValidationErrors
+	{{ validations $ }}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+{{ block "storage" . }}type {{$.TypeName}}Storage interface {
 	DB() interface{}
-	List(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}}
-	One(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ pkattributes $pks  }}) ({{$typename}}, error)
-	Add(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, o {{$typename}}) ({{$typename}}, error)
-	Update(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, o {{$typename}}) (error)
-	Delete(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ pkattributes $pks }}) (error)
-{{ range $idx, $bt := .BelongsTo}}
-	ListBy{{$bt.Parent}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid int) []{{$typename}}
-	OneBy{{$bt.Parent}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid, id int) ({{$typename}}, error)
+	List(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, preloads ...Preload) []{{$.TypeName}}
+	One(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ pkattributes $ }}, preloads ...Preload) ({{$.TypeName}}, error)
+	Add(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, o {{$.TypeName}}) ({{$.TypeName}}, error)
+	Update(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, o {{$.TypeName}}) ({{ if $.DoVersioned }}{{$.TypeName}}, {{ end }}error)
+	Delete(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ pkattributes $ }}) (error)
+{{ if $.DoSoftDelete }}	Restore(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ pkattributes $ }}) error
+	HardDelete(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ pkattributes $ }}) error
+	ListDeleted(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}) []{{$.TypeName}}
+	ListWithDeleted(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}) []{{$.TypeName}}
+{{ end }}{{ range $idx, $bt := .BelongsTo}}
+	ListBy{{$bt.Role}}(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, parentid {{$bt.KeyType}}) []{{$.TypeName}}
+	OneBy{{$bt.Role}}(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, parentid {{$bt.KeyType}}, {{ pkattributes $ }}) ({{$.TypeName}}, error)
 {{end}}
-	{{ storagedef $typedef }}
-}
+{{ if eq (len $.PrimaryKeys) 1 }}{{ range .Children }}{{ if .Many }}	List{{.Field}}(ctx context.Context, parentID {{$.PKType}}) []{{.Package}}.{{.Model}}
+	Add{{.Name}}(ctx context.Context, parentID {{$.PKType}}, child {{.Package}}.{{.Model}}) ({{.Package}}.{{.Model}}, error)
+{{ else }}	Get{{.Name}}(ctx context.Context, parentID {{$.PKType}}) ({{.Package}}.{{.Model}}, error)
+	Set{{.Name}}(ctx context.Context, parentID {{$.PKType}}, child {{.Package}}.{{.Model}}) ({{.Package}}.{{.Model}}, error)
+{{ end }}{{ end }}{{ end }}{{ range .Polymorphics }}	ListBy{{.Name}}(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ goify .Name false }}Type string, {{ goify .Name false }}ID {{.KeyType}}) []{{$.TypeName}}
+{{ end }}{{ range .Uniques }}	OneBy{{.Name}}(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}{{ range .Columns }}, {{ goify .Column false }} {{ .Coltype }}{{ end }}) ({{$.TypeName}}, error)
+{{ end }}
+	{{ storagedef $ }}
+}{{ end }}
 type {{$typename}}DB struct {
 	Db gorm.DB
 	{{ if .DoCache }}cache *cache.Cache{{end}}
 }
+// {{$typename}}FilterByKey returns a scope selecting the {{$typename}} identified by its primary key.
+func {{$typename}}FilterByKey({{ pkattributes $ }}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("{{ pkwhere $ }}", {{ pkwherefields $ }})
+	}
+}
 {{ range $idx, $bt := .BelongsTo}}
-func {{$typename}}FilterBy{{$bt.Parent}}(parentid int, originaldb *gorm.DB) func(db *gorm.DB) *gorm.DB {
-	if parentid > 0 {
+func {{$typename}}FilterBy{{$bt.Role}}(parentid {{$bt.KeyType}}, originaldb *gorm.DB) func(db *gorm.DB) *gorm.DB {
+	if {{ keyisset $bt.KeyType "parentid" }} {
 		return func(db *gorm.DB) *gorm.DB {
-			return db.Where("{{ $bt.DatabaseField }}_id = ?", parentid)
+			return db.Where("{{ $bt.Column }} = ?", parentid)
 		}
 	} else {
 		return func(db *gorm.DB) *gorm.DB {
@@ -49,24 +112,24 @@ func {{$typename}}FilterBy{{$bt.Parent}}(parentid int, originaldb *gorm.DB) func
 	}
 }
 
-func (m *{{$typename}}DB) ListBy{{$bt.Parent}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid int) []{{$typename}} {
+func (m *{{$typename}}DB) ListBy{{$bt.Role}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid {{$bt.KeyType}}) []{{$typename}} {
 
 	var objs []{{$typename}}
-	m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Scopes({{$typename}}FilterBy{{$bt.Parent}}(parentid, &m.Db)).Find(&objs)
+	m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Scopes({{$typename}}FilterBy{{$bt.Role}}(parentid, &m.Db)).Find(&objs)
 	return objs
 }
 
-func (m *{{$typename}}DB) OneBy{{$bt.Parent}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid, {{ pkattributes $pks }}) ({{$typename}}, error) {
+func (m *{{$typename}}DB) OneBy{{$bt.Role}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, parentid {{$bt.KeyType}}, {{ pkattributes $ }}) ({{$typename}}, error) {
 	{{ if $cached }}//first attempt to retrieve from cache
-	o,found := m.cache.Get(strconv.Itoa(id))
+	o,found := m.cache.Get(fmt.Sprint({{ pkname $ }}))
 	if found {
 		return o.({{$typename}}), nil
 	}
 	// fallback to database if not found{{ end }}
 	var obj {{$typename}}
 
-	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Scopes({{$typename}}FilterBy{{$bt.Parent}}(parentid, &m.Db)).Find(&obj, id).Error
-	{{ if $cached }} go m.cache.Set(strconv.Itoa(id), obj, cache.DefaultExpiration) {{ end }}
+	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Scopes({{$typename}}FilterBy{{$bt.Role}}(parentid, &m.Db), {{$typename}}FilterByKey({{ pkname $ }})).Find(&obj).Error
+	{{ if $cached }} go m.cache.Set(fmt.Sprint({{ pkname $ }}), obj, cache.DefaultExpiration) {{ end }}
 	return obj, err
 }
 {{end}}
@@ -87,95 +150,227 @@ func (m *{{$typename}}DB) DB() interface{} {
 	return &m.Db
 }
 
-func (m *{{$typename}}DB) List(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}} {
+// Preload selects an association of the {{$typename}} loaded along with it by
+// One and List.
+type Preload func(db *gorm.DB) *gorm.DB
+{{ range .Preloads }}
+// With{{.}} preloads the {{.}} of the {{$typename}}.
+func With{{.}}() Preload {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload("{{.}}")
+	}
+}
+{{ end }}
+{{ block "list" . }}func (m *{{$.TypeName}}DB) List(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, preloads ...Preload) []{{$.TypeName}} {
 
-	var objs []{{$typename}}
-	m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Find(&objs)
+	var objs []{{$.TypeName}}
+	db := {{ if $.DoDynamicTableName }}m.Db.Table(tableName){{ else }}&m.Db{{ end }}
+	for _, p := range preloads {
+		db = p(db)
+	}
+	db.Find(&objs)
 	return objs
-}
+}{{ end }}
 
 
-{{ range $idx, $col := columns .TypeDef.AttributeDefinition }}
+{{ range $idx, $col := columns $ }}
 func (m *{{$typename}}DB) ListBy{{title $col.Column}}Equal(ctx context.Context, {{lower $col.Column}} {{$col.Coltype}}{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}} {
 
 	var objs []{{$typename}}
 	m.Db.Where("{{lower $col.Column}} = ?",  {{lower $col.Column}}){{ if $dynamictable }}.Table(tableName){{ end }}.Find(&objs)
 	return objs
 }
-func (m *{{$typename}}DB) ListBy{{title $col.Column}}Like(ctx context.Context, {{lower $col.Column}} {{$col.Coltype}}{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}} {
+{{ if not $col.Enum }}func (m *{{$typename}}DB) ListBy{{title $col.Column}}Like(ctx context.Context, {{lower $col.Column}} {{$col.Coltype}}{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}} {
 
 	var objs []{{$typename}}
 	m.Db.Where("{{lower $col.Column}} like ?",  {{lower $col.Column}}){{ if $dynamictable }}.Table(tableName){{ end }}.Find(&objs)
 	return objs
-}
+}{{ end }}
 {{ end  }}
+{{ range .Polymorphics }}
+// ListBy{{.Name}} returns the {{$typename}}s belonging to the {{ snake .Name }}
+// of the given type, the table name of the parent model, and key.
+func (m *{{$typename}}DB) ListBy{{.Name}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ goify .Name false }}Type string, {{ goify .Name false }}ID {{.KeyType}}) []{{$typename}} {
+	var objs []{{$typename}}
+	m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Where("{{ snake .Name }}_type = ? AND {{ snake .Name }}_id = ?", {{ goify .Name false }}Type, {{ goify .Name false }}ID).Find(&objs)
+	return objs
+}
+{{ end }}{{ range .Uniques }}
+// OneBy{{.Name}} returns the {{$typename}} identified by the unique {{ range $i, $c := .Columns }}{{ if $i }}, {{ end }}{{ $c.Column }}{{ end }}.
+func (m *{{$typename}}DB) OneBy{{.Name}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}{{ range .Columns }}, {{ goify .Column false }} {{ .Coltype }}{{ end }}) ({{$typename}}, error) {
+	var obj {{$typename}}
+	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Where("{{ range $i, $c := .Columns }}{{ if $i }} AND {{ end }}{{ snake (goify $c.Column true) }} = ?{{ end }}"{{ range .Columns }}, {{ goify .Column false }}{{ end }}).Find(&obj).Error
+	return obj, err
+}
+{{ end }}
+{{ if .Indexes }}
+// AddIndexes creates the indexes of the "#indexes" metadata with their
+// columns in the declared order.
+func (m *{{$typename}}DB) AddIndexes() error {
+{{ range .Indexes }}	if err := m.Db.Model(&{{$typename}}{}).{{ if .Unique }}AddUniqueIndex{{ else }}AddIndex{{ end }}("{{.Name}}"{{ range .Columns }}, "{{ snake (goify . true) }}"{{ end }}).Error; err != nil {
+		return err
+	}
+{{ end }}	return nil
+}
+{{ end }}
+{{ if .Checks }}
+// AddConstraints adds the CHECK constraints derived from the Minimum and
+// Maximum validations of the design to the table. It fails on constraints
+// that already exist.
+func (m *{{$typename}}DB) AddConstraints() error {
+	table := m.Db.NewScope(&{{$typename}}{}).TableName()
+{{ range .Checks }}	if err := m.Db.Exec("ALTER TABLE " + table + " ADD CONSTRAINT {{.Name}} CHECK ({{.Expr}})").Error; err != nil {
+		return err
+	}
+{{ end }}	return nil
+}
+{{ end }}
 
 
-func (m *{{$typename}}DB) One(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{pkattributes $pks}}) ({{$typename}}, error) {
-	{{ if $cached }}//first attempt to retrieve from cache
-	o,found := m.cache.Get(strconv.Itoa(id))
-	if found {
-		return o.({{$typename}}), nil
+{{ block "one" . }}func (m *{{$.TypeName}}DB) One(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ pkattributes $ }}, preloads ...Preload) ({{$.TypeName}}, error) {
+	{{ if $.DoCache }}//first attempt to retrieve from cache, which doesn't hold the preloaded associations
+	if len(preloads) == 0 {
+		o,found := m.cache.Get(fmt.Sprint({{ pkname $ }}))
+		if found {
+			return o.({{$.TypeName}}), nil
+		}
 	}
 	// fallback to database if not found{{ end }}
-	var obj {{$typename}}
-	{{ $l := len $pks }}
-	{{ if eq $l 1 }}
-	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Find(&obj, id).Error
-	{{ else  }}
-	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Find(&obj).Where("{{pkwhere $pks}}", {{pkwherefields $pks}}).Error
-	{{ end }}
-	{{ if $cached }} go m.cache.Set(strconv.Itoa(id), obj, cache.DefaultExpiration) {{ end }}
+	var obj {{$.TypeName}}
+	db := m.Db{{ if $.DoDynamicTableName }}.Table(tableName){{ end }}.Scopes({{$.TypeName}}FilterByKey({{ pkname $ }})){{ if $.DoEager }}{{ range $.Children }}.Preload("{{.Field}}"){{ end }}{{ end }}
+	for _, p := range preloads {
+		db = p(db)
+	}
+	err := db.Find(&obj).Error
+	{{ if $.DoCache }}if len(preloads) == 0 {
+		go m.cache.Set(fmt.Sprint({{ pkname $ }}), obj, cache.DefaultExpiration)
+	}{{ end }}
 	return obj, err
+}{{ end }}
+{{ if eq (len $pks) 1 }}{{ range .Children }}{{ if .Many }}
+// List{{.Field}} returns the {{.Field}} of the {{$typename}} with the given key.
+func (m *{{$typename}}DB) List{{.Field}}(ctx context.Context, parentID {{$pktype}}) []{{.Package}}.{{.Model}} {
+	var list []{{.Package}}.{{.Model}}
+	m.Db.{{ childfilter $ . }}.Find(&list)
+	return list
 }
 
-func (m *{{$typename}}DB) Add(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, model {{$typename}}) ({{$typename}}, error) {
-	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Create(&model).Error
-	{{ if $cached }} go m.cache.Set(strconv.Itoa(model.ID), model, cache.DefaultExpiration) {{ end }}
-	return model, err
+// Add{{.Name}} creates child as one of the {{.Field}} of the {{$typename}}
+// with the given key.
+func (m *{{$typename}}DB) Add{{.Name}}(ctx context.Context, parentID {{$pktype}}, child {{.Package}}.{{.Model}}) ({{.Package}}.{{.Model}}, error) {
+	{{ childassign $ . }}
+	err := m.Db.Create(&child).Error
+	return child, err
+}
+{{ else }}
+// Get{{.Name}} returns the {{.Name}} of the {{$typename}} with the given key.
+func (m *{{$typename}}DB) Get{{.Name}}(ctx context.Context, parentID {{$pktype}}) ({{.Package}}.{{.Model}}, error) {
+	var child {{.Package}}.{{.Model}}
+	err := m.Db.{{ childfilter $ . }}.First(&child).Error
+	return child, err
+}
+
+// Set{{.Name}} saves child as the {{.Name}} of the {{$typename}} with the
+// given key, creating it if it has no primary key.
+func (m *{{$typename}}DB) Set{{.Name}}(ctx context.Context, parentID {{$pktype}}, child {{.Package}}.{{.Model}}) ({{.Package}}.{{.Model}}, error) {
+	{{ childassign $ . }}
+	err := m.Db.Save(&child).Error
+	return child, err
 }
+{{ end }}{{ end }}{{ end }}
 
-func (m *{{$typename}}DB) Update(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, model {{$typename}}) error {
-	obj, err := m.One(ctx{{ if $dynamictable }}, tableName{{ end }}, {{pkupdatefields $pks}})
+{{ block "add" . }}func (m *{{$.TypeName}}DB) Add(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, model {{$.TypeName}}) ({{$.TypeName}}, error) {
+	{{ if $.DoValidate }}if err := model.Validate(); err != nil {
+		return model, err
+	}
+	{{ end }}err := m.Db{{ if $.DoDynamicTableName }}.Table(tableName){{ end }}.Create(&model).Error
+	{{ if $.DoCache }} go m.cache.Set(fmt.Sprint({{ pkupdatefields $ }}), model, cache.DefaultExpiration) {{ end }}
+	return model, err
+}{{ end }}
+
+{{ block "update" . }}{{ if $.DoVersioned }}// Update saves the {{$.TypeName}} if its version is still the stored one and
+// returns it with the new version, to pass to the next Update.
+{{ end }}func (m *{{$.TypeName}}DB) Update(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, model {{$.TypeName}}) ({{ if $.DoVersioned }}{{$.TypeName}}, {{ end }}error) {
+	{{ if $.DoValidate }}if err := model.Validate(); err != nil {
+		return {{ if $.DoVersioned }}model, {{ end }}err
+	}
+	{{ end }}{{ if $.DoVersioned }}version := model.Version
+	model.Version++
+	res := m.Db{{ if $.DoDynamicTableName }}.Table(tableName){{ end }}.Model(&{{$.TypeName}}{}).Scopes({{$.TypeName}}FilterByKey({{ pkupdatefields $ }})).Where("version = ?", version).Updates(model)
+	err := res.Error
+	if err == nil && res.RowsAffected == 0 {
+		err = ErrVersionConflict
+	}
+	if err != nil {
+		model.Version = version
+		return model, err
+	}{{ else }}obj, err := m.One(ctx{{ if $.DoDynamicTableName }}, tableName{{ end }}, {{ pkupdatefields $ }})
 	if err != nil {
 		return  err
 	}
-	err = m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Model(&obj).Updates(model).Error
-	{{ if $cached }}
+	err = m.Db{{ if $.DoDynamicTableName }}.Table(tableName){{ end }}.Model(&obj).Updates(model).Error{{ end }}
+	{{ if $.DoCache }}
 	go func(){
-	obj, err := m.One(ctx, model.ID)
+	obj, err := m.One(ctx{{ if $.DoDynamicTableName }}, tableName{{ end }}, {{ pkupdatefields $ }})
 	if err == nil {
-		m.cache.Set(strconv.Itoa(model.ID), obj, cache.DefaultExpiration)
+		m.cache.Set(fmt.Sprint({{ pkupdatefields $ }}), obj, cache.DefaultExpiration)
 	}
 	}()
 	{{ end }}
 
-	return err
-}
+	return {{ if $.DoVersioned }}model, {{ end }}err
+}{{ end }}
 
 
-func (m *{{$typename}}DB) Delete(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{pkattributes $pks}})  error {
-	var obj {{$typename}}
-	{{ $l := len $pks }}
-	{{ if eq $l 1 }}
-	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Delete(&obj, id).Error
-	{{ else  }}
-	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Delete(&obj).Where("{{pkwhere $pks}}", {{pkwherefields $pks}}).Error
-	{{ end }}
+{{ block "delete" . }}func (m *{{$.TypeName}}DB) Delete(ctx context.Context{{ if $.DoDynamicTableName }}, tableName string{{ end }}, {{ pkattributes $ }})  error {
+	var obj {{$.TypeName}}
+	err := m.Db{{ if $.DoDynamicTableName }}.Table(tableName){{ end }}.Scopes({{$.TypeName}}FilterByKey({{ pkname $ }})).Delete(&obj).Error
 	if err != nil {
 		return  err
 	}
-	{{ if $cached }} go m.cache.Delete(strconv.Itoa(id)) {{ end }}
+	{{ if $.DoCache }} go m.cache.Delete(fmt.Sprint({{ pkname $ }})) {{ end }}
 	return  nil
+}{{ end }}
+
+{{ if .DoSoftDelete }}
+// Restore undeletes the soft deleted {{$typename}} with the given key.
+func (m *{{$typename}}DB) Restore(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ pkattributes $ }}) error {
+	err := m.Db.Unscoped(){{ if $dynamictable }}.Table(tableName){{ end }}.Model(&{{$typename}}{}).Scopes({{$typename}}FilterByKey({{ pkname $ }})).Update("deleted_at", nil).Error
+	{{ if $cached }} go m.cache.Delete(fmt.Sprint({{ pkname $ }})) {{ end }}
+	return err
+}
+
+// HardDelete removes the {{$typename}} with the given key from the database,
+// whether it is soft deleted or not.
+func (m *{{$typename}}DB) HardDelete(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{ pkattributes $ }}) error {
+	var obj {{$typename}}
+	err := m.Db.Unscoped(){{ if $dynamictable }}.Table(tableName){{ end }}.Scopes({{$typename}}FilterByKey({{ pkname $ }})).Delete(&obj).Error
+	{{ if $cached }} go m.cache.Delete(fmt.Sprint({{ pkname $ }})) {{ end }}
+	return err
+}
+
+// ListDeleted returns the soft deleted {{$typename}}s.
+func (m *{{$typename}}DB) ListDeleted(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}} {
+	var objs []{{$typename}}
+	m.Db.Unscoped(){{ if $dynamictable }}.Table(tableName){{ end }}.Where("deleted_at IS NOT NULL").Find(&objs)
+	return objs
 }
 
+// ListWithDeleted returns all the {{$typename}}s, including the soft deleted
+// ones.
+func (m *{{$typename}}DB) ListWithDeleted(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}) []{{$typename}} {
+	var objs []{{$typename}}
+	m.Db.Unscoped(){{ if $dynamictable }}.Table(tableName){{ end }}.Find(&objs)
+	return objs
+}
+{{ end }}
 {{ range $idx, $bt := .M2M}}
-func (m *{{$typename}}DB) Delete{{$bt.Relation}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{lower $typename}}ID,  {{$bt.LowerRelation}}ID int)  error {
+func (m *{{$typename}}DB) Delete{{$bt.Relation}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{lower $typename}}ID {{$pktype}}, {{$bt.LowerRelation}}ID {{$bt.KeyType}})  error {
 	var obj {{$typename}}
-	obj.ID = {{lower $typename}}ID
+	{{ pkassign $ "obj" (printf "%sID" (lower $typename)) }}
 	var assoc {{$bt.LowerRelation}}.{{$bt.Relation}}
 	var err error
-	assoc.ID = {{$bt.LowerRelation}}ID
+	assoc.{{$bt.KeyField}} = {{$bt.LowerRelation}}ID
 	if err != nil {
 		return err
 	}
@@ -185,34 +380,140 @@ func (m *{{$typename}}DB) Delete{{$bt.Relation}}(ctx context.Context{{ if $dynam
 	}
 	return  nil
 }
-func (m *{{$typename}}DB) Add{{$bt.Relation}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{lower $typename}}ID, {{$bt.LowerRelation}}ID int) error {
+{{ if $bt.Join }}
+// {{$bt.Relation}}WithJoin holds a {{$bt.Relation}} related to the {{$typename}}
+// along with the row of the join table.
+type {{$bt.Relation}}WithJoin struct {
+	{{$bt.Relation}} {{$bt.LowerRelation}}.{{$bt.Relation}}
+	Join {{$bt.LowerJoin}}.{{$bt.Join}}
+}
+
+// Add{{$bt.Relation}} creates the join row attrs relating the {{$bt.Relation}}
+// to the {{$typename}}.
+func (m *{{$typename}}DB) Add{{$bt.Relation}}(ctx context.Context, {{lower $typename}}ID {{$pktype}}, {{$bt.LowerRelation}}ID {{$bt.KeyType}}, attrs {{$bt.LowerJoin}}.{{$bt.Join}}) error {
+	attrs.{{$bt.JoinParent.Role}}ID = {{$bt.JoinParent.KeyType}}({{lower $typename}}ID)
+	attrs.{{$bt.JoinChild.Role}}ID = {{$bt.JoinChild.KeyType}}({{$bt.LowerRelation}}ID)
+	return m.Db.Create(&attrs).Error
+}
+
+// Update{{$bt.Relation}} updates the join row relating the {{$bt.Relation}}
+// to the {{$typename}} with the non zero fields of attrs.
+func (m *{{$typename}}DB) Update{{$bt.Relation}}(ctx context.Context, {{lower $typename}}ID {{$pktype}}, {{$bt.LowerRelation}}ID {{$bt.KeyType}}, attrs {{$bt.LowerJoin}}.{{$bt.Join}}) error {
+	return m.Db.Model(&{{$bt.LowerJoin}}.{{$bt.Join}}{}).Where("{{$bt.JoinParent.Column}} = ? AND {{$bt.JoinChild.Column}} = ?", {{lower $typename}}ID, {{$bt.LowerRelation}}ID).Updates(attrs).Error
+}
+
+// List{{$bt.PluralRelation}}WithJoin returns the {{$bt.PluralRelation}} related to
+// the {{$typename}} along with their join rows.
+func (m *{{$typename}}DB) List{{$bt.PluralRelation}}WithJoin(ctx context.Context, {{lower $typename}}ID {{$pktype}}) []{{$bt.Relation}}WithJoin {
+	var joins []{{$bt.LowerJoin}}.{{$bt.Join}}
+	m.Db.Where("{{$bt.JoinParent.Column}} = ?", {{lower $typename}}ID).Find(&joins)
+	if len(joins) == 0 {
+		return nil
+	}
+	ids := make([]{{$bt.JoinChild.KeyType}}, len(joins))
+	for i, j := range joins {
+		ids[i] = j.{{$bt.JoinChild.Role}}ID
+	}
+	var related []{{$bt.LowerRelation}}.{{$bt.Relation}}
+	m.Db.Where("{{$bt.KeyColumn}} in (?)", ids).Find(&related)
+	byKey := make(map[{{$bt.JoinChild.KeyType}}]{{$bt.LowerRelation}}.{{$bt.Relation}}, len(related))
+	for _, r := range related {
+		byKey[{{$bt.JoinChild.KeyType}}(r.{{$bt.KeyField}})] = r
+	}
+	list := make([]{{$bt.Relation}}WithJoin, 0, len(joins))
+	for _, j := range joins {
+		if r, ok := byKey[j.{{$bt.JoinChild.Role}}ID]; ok {
+			list = append(list, {{$bt.Relation}}WithJoin{ {{$bt.Relation}}: r, Join: j})
+		}
+	}
+	return list
+}
+{{ else }}
+func (m *{{$typename}}DB) Add{{$bt.Relation}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{lower $typename}}ID {{$pktype}}, {{$bt.LowerRelation}}ID {{$bt.KeyType}}) error {
 	var {{lower $typename}} {{$typename}}
-	{{lower $typename}}.ID = {{lower $typename}}ID
+	{{ pkassign $ (lower $typename) (printf "%sID" (lower $typename)) }}
 	var assoc {{$bt.LowerRelation}}.{{$bt.Relation}}
-	assoc.ID = {{$bt.LowerRelation}}ID
+	assoc.{{$bt.KeyField}} = {{$bt.LowerRelation}}ID
 	err := m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Model(&{{lower $typename}}).Association("{{$bt.PluralRelation}}").Append(assoc).Error
 	if err != nil {
 		return  err
 	}
 	return  nil
-}
-func (m *{{$typename}}DB) List{{$bt.PluralRelation}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{lower $typename}}ID int)  []{{$bt.LowerRelation}}.{{$bt.Relation}} {
+}{{ end }}
+func (m *{{$typename}}DB) List{{$bt.PluralRelation}}(ctx context.Context{{ if $dynamictable }}, tableName string{{ end }}, {{lower $typename}}ID {{$pktype}})  []{{$bt.LowerRelation}}.{{$bt.Relation}} {
 	var list []{{$bt.LowerRelation}}.{{$bt.Relation}}
 	var obj {{$typename}}
-	obj.ID = {{lower $typename}}ID
+	{{ pkassign $ "obj" (printf "%sID" (lower $typename)) }}
 	m.Db{{ if $dynamictable }}.Table(tableName){{ end }}.Model(&obj).Association("{{$bt.PluralRelation}}").Find(&list)
 	return  list
 }
 {{end}}
 {{ range $idx, $bt := .BelongsTo}}
-func Filter{{$typename}}By{{$bt.Parent}}(parent *int, list []{{$typename}}) []{{$typename}} {
+func Filter{{$typename}}By{{$bt.Role}}(parent *{{$bt.KeyType}}, list []{{$typename}}) []{{$typename}} {
 	var filtered []{{$typename}}
 	for _,o := range list {
-		if o.{{$bt.Parent}}ID == int(*parent) {
+		if {{ if $bt.Nullable }}o.{{$bt.Role}}ID != nil && *{{ end }}o.{{$bt.Role}}ID == *parent {
 			filtered = append(filtered,o)
 		}
 	}
 	return filtered
 }
 {{end}}
+{{ block "extra" . }}{{ end }}
+{{ define "enum" }}
+// {{.TypeName}} is the type of the {{.Attribute}} field, its values are
+// restricted to the constants below.
+type {{.TypeName}} {{.Base}}
+
+const ({{ range .Values }}
+	{{.Const}} {{$.TypeName}} = {{.Literal}}{{ end }}
+)
+
+// IsValid returns true if e is one of the {{.TypeName}} constants.
+func (e {{.TypeName}}) IsValid() bool {
+	switch e {
+	case {{ range $i, $v := .Values }}{{ if $i }}, {{ end }}{{$v.Const}}{{ end }}:
+		return true
+	}
+	return false
+}
+
+// String returns the value of e.
+func (e {{.TypeName}}) String() string {
+	{{ if eq .Base "string" }}return string(e){{ else }}return strconv.Itoa(int(e)){{ end }}
+}
+
+// Scan implements sql.Scanner, it rejects unknown values.
+func (e *{{.TypeName}}) Scan(src interface{}) error {
+	var v {{.TypeName}}
+	switch s := src.(type) {
+	{{ if eq .Base "string" }}case string:
+		v = {{.TypeName}}(s)
+	case []byte:
+		v = {{.TypeName}}(s){{ else }}case int64:
+		v = {{.TypeName}}(s)
+	case []byte:
+		n, err := strconv.Atoi(string(s))
+		if err != nil {
+			return fmt.Errorf("invalid {{.TypeName}} value %q", s)
+		}
+		v = {{.TypeName}}(n){{ end }}
+	default:
+		return fmt.Errorf("cannot scan %T into a {{.TypeName}}", src)
+	}
+	if !v.IsValid() {
+		return fmt.Errorf("invalid {{.TypeName}} value %q", v.String())
+	}
+	*e = v
+	return nil
+}
+
+// Value implements driver.Valuer, it rejects unknown values.
+func (e {{.TypeName}}) Value() (driver.Value, error) {
+	if !e.IsValid() {
+		return nil, fmt.Errorf("invalid {{.TypeName}} value %q", e.String())
+	}
+	return {{ if eq .Base "string" }}string(e){{ else }}int64(e){{ end }}, nil
+}
+{{ end }}
 `
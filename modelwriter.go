@@ -1,6 +1,7 @@
 package gorma
 
 import (
+	"fmt"
 	"strings"
 	"text/template"
 
@@ -17,37 +18,130 @@ type ModelWriter struct {
 type Field struct {
 	Column  string
 	Coltype string
+	// Enum is true if Coltype is an enum type.
+	Enum bool
 }
+
+// BelongsTo describes a parent model of a model.
 type BelongsTo struct {
-	Parent        string
+	// Parent is the name of the parent model, e.g. "User".
+	Parent string
+	// Role names the relation, e.g. "Reviewer". The foreign key field is
+	// Role + "ID" and the methods are named after it. It defaults to Parent.
+	Role string
+	// DatabaseField is the snake case name of the role.
 	DatabaseField string
+	// Column is the name of the foreign key column, DatabaseField + "_id"
+	// by default.
+	Column string
+	// KeyType is the Go type of the foreign key, the type of the primary
+	// key of the parent by default.
+	KeyType string
+	// KeyPackage is the import path of the package of KeyType, empty for
+	// builtin types.
+	KeyPackage string
+	// Nullable is true if the parent is optional, the foreign key field is
+	// then a pointer.
+	Nullable bool
+	// ParentField is the name of the field holding the parent, Role, or
+	// empty if the parent package imports the package of the model.
+	ParentField string
+	// ParentKey is the name of the primary key field of the parent.
+	ParentKey string
 }
+
+// Many2Many describes a many to many relationship of a model.
 type Many2Many struct {
+	// Relation is the name of the related model, e.g. "Industry".
 	Relation            string
 	LowerRelation       string
 	PluralRelation      string
 	LowerPluralRelation string
-	TableName           string
+	// TableName is the name of the join table.
+	TableName string
+	// KeyField and KeyType are the Go name and type of the primary key of
+	// the related model, KeyPackage the import path of the package of
+	// KeyType and KeyColumn its column.
+	KeyField   string
+	KeyType    string
+	KeyPackage string
+	KeyColumn  string
+	// Join is the name of the join model given as fourth part of the
+	// "#many2many" entry, empty when gorm manages the join table.
+	Join      string
+	LowerJoin string
+	// JoinParent and JoinChild are the relations of the join model to the
+	// model and to the related model.
+	JoinParent BelongsTo
+	JoinChild  BelongsTo
 }
+
+// ModelData is the data the model template is rendered with.
 type ModelData struct {
-	TypeDef            *design.UserTypeDefinition
-	TypeName           string
-	ModelUpper         string
-	ModelLower         string
-	BelongsTo          []BelongsTo
-	M2M                []Many2Many
-	PrimaryKeys        map[string]PrimaryKey
-	CustomTableName    string
+	// TypeDef is the user type the model is generated from.
+	TypeDef *design.UserTypeDefinition
+	// TypeName is the name of the model type, e.g. "User" for "UserModel".
+	TypeName   string
+	ModelUpper string
+	ModelLower string
+	BelongsTo  []BelongsTo
+	M2M        []Many2Many
+	// PrimaryKeys lists the primary key fields in struct order.
+	PrimaryKeys []PrimaryKey
+	// PKField and PKType are the Go name and type of the first primary key.
+	PKField         string
+	PKType          string
+	CustomTableName string
+	// The Do fields are set by the corresponding metadata tags.
 	DoMedia            bool
 	DoRoler            bool
 	DoCustomTableName  bool
 	DoDynamicTableName bool
 	DoCache            bool
-	APIVersion         string
-	RequiredPackages   map[string]bool
+	// DoValidate is true unless the "#novalidate" metadata is set, Add and
+	// Update then call Validate.
+	DoValidate bool
+	// DoVersioned is set by the "#versioned" metadata: the model gets a
+	// Version field and Update fails with ErrVersionConflict when the
+	// version changed since the model was read.
+	DoVersioned bool
+	// DoEager is set by the "#eager" metadata: One preloads the children.
+	DoEager bool
+	// DoTimestamps and DoSoftDelete are true unless turned off by the
+	// "#timestamps" and "#softdelete" metadata, or by "#skipts".
+	DoTimestamps bool
+	DoSoftDelete bool
+	// APIVersion is the name of the goa app package of the API version.
+	APIVersion string
+	// Enums lists the types generated for the attributes with an Enum
+	// validation.
+	Enums []Enum
+	// Indexes lists the indexes declared with the "#indexes" metadata.
+	Indexes []Index
+	// Checks lists the CHECK constraints created by AddConstraints.
+	Checks []Check
+	// Uniques lists the unique constraints a OneBy finder is generated for.
+	Uniques []Unique
+	// Polymorphics lists the polymorphic relations of the model.
+	Polymorphics []Polymorphic
+	// Children lists the fields holding the children of the "#hasmany" and
+	// "#hasone" relations.
+	Children []Child
+	// Preloads lists the association fields a With option is generated
+	// for: the children, the many2many relations and the parents.
+	Preloads []string
+	// RequiredPackages lists the other model packages the model imports.
+	RequiredPackages map[string]bool
+	// Imports lists additional imports of the generated file.
+	Imports []*codegen.ImportSpec
+	// Sections lists additional struct fields, rendered after the built-in
+	// ones.
+	Sections []ModelSection
 }
 
-func NewModelData(version string, utd *design.UserTypeDefinition) ModelData {
+// NewModelData returns the data used to render the model generated for the
+// given user type.
+func NewModelData(v *design.APIVersionDefinition, utd *design.UserTypeDefinition) (ModelData, error) {
 	md := ModelData{
 		TypeDef:          utd,
 		RequiredPackages: make(map[string]bool, 0),
@@ -57,39 +151,87 @@ func NewModelData(version string, utd *design.UserTypeDefinition) ModelData {
 	md.TypeName = tn
 	md.ModelUpper = upper(tn)
 	md.ModelLower = lower(tn)
-	if version != "" {
-		md.APIVersion = codegen.VersionPackage(version)
+	if v.Version != "" {
+		md.APIVersion = codegen.VersionPackage(v.Version)
 	} else {
 		md.APIVersion = "app"
 	}
-	md.PrimaryKeys = getPrimaryKeys(utd)
+	md.Enums = getEnums(tn, utd.Definition())
+	if err := checkEnums(utd, tn, md.Enums); err != nil {
+		return md, err
+	}
+	pks, err := getPrimaryKeys(utd)
+	if err != nil {
+		return md, err
+	}
+	if md.Indexes, err = getIndexes(utd); err != nil {
+		return md, err
+	}
+	md.Uniques = getUniques(&md)
+	if err = checkDefaults(utd); err != nil {
+		return md, err
+	}
+	md.Checks = getChecks(tn, utd.Definition())
+	if md.Polymorphics, err = getPolymorphics(utd); err != nil {
+		return md, err
+	}
+	md.PrimaryKeys = pks
+	if len(md.PrimaryKeys) == 1 {
+		md.PKField = codegen.Goify(md.PrimaryKeys[0].Field, true)
+		md.PKType = md.PrimaryKeys[0].Type
+	} else {
+		md.PKType = tn + "Key"
+	}
 
-	var belongs []BelongsTo
-	if bt, ok := metaLookup(utd.Metadata, BELONGSTO); ok {
-		btlist := strings.Split(bt, ",")
-		for _, s := range btlist {
-			binst := BelongsTo{
-				Parent:        s,
-				DatabaseField: camelToSnake(s),
-			}
-			belongs = append(belongs, binst)
+	if md.BelongsTo, err = parseBelongsTo(v, utd.TypeName, utd.Metadata); err != nil {
+		return md, err
+	}
+	for i := range md.BelongsTo {
+		bt := &md.BelongsTo[i]
+		if setParentField(v, tn, bt) && bt.Parent != tn {
+			md.RequiredPackages[lower(bt.Parent)] = true
 		}
 	}
-	md.BelongsTo = belongs
 
 	var m2m []Many2Many
 	if m2, ok := metaLookup(utd.Metadata, M2M); ok {
 		mlist := strings.Split(m2, ",")
 		for _, s := range mlist {
 			parms := strings.Split(s, ":")
-			if len(parms) == 3 {
+			if len(parms) == 3 || len(parms) == 4 {
 
+				key := modelKey(v, parms[1])
 				minst := Many2Many{
 					Relation:            parms[1],
 					LowerRelation:       lower(parms[1]),
 					PluralRelation:      parms[0],
 					LowerPluralRelation: lower(parms[0]),
 					TableName:           parms[2],
+					KeyField:            codegen.Goify(key.Field, true),
+					KeyType:             key.Type,
+					KeyPackage:          key.Package,
+					KeyColumn:           key.Column,
+				}
+				if minst.KeyColumn == "" {
+					minst.KeyColumn = "id"
+				}
+				if len(parms) == 4 {
+					fail := func(err error) error {
+						return &GenerationError{TypeName: utd.TypeName, Key: M2M, Err: err}
+					}
+					join := lookupModel(v, parms[3])
+					if join == nil {
+						return md, fail(fmt.Errorf("unknown join model %q", parms[3]))
+					}
+					if len(md.PrimaryKeys) != 1 {
+						return md, fail(fmt.Errorf("join models require a single primary key"))
+					}
+					minst.Join = deModel(parms[3])
+					minst.LowerJoin = lower(minst.Join)
+					if minst.JoinParent, minst.JoinChild, err = joinRelations(v, join, tn, parms[1]); err != nil {
+						return md, fail(err)
+					}
+					md.RequiredPackages[minst.LowerJoin] = true
 				}
 				m2m = append(m2m, minst)
 
@@ -100,22 +242,38 @@ func NewModelData(version string, utd *design.UserTypeDefinition) ModelData {
 	}
 	md.M2M = m2m
 
-	if many, ok := metaLookup(utd.Metadata, "#hasmany"); ok {
+	if many, ok := metaLookup(utd.Metadata, HASMANY); ok {
 		list := strings.Split(many, ",")
 		for _, s := range list {
 			md.RequiredPackages[lower(s)] = true
 		}
 	}
 
-	if children, ok := metaLookup(utd.Metadata, "#hasone"); ok {
+	if children, ok := metaLookup(utd.Metadata, HASONE); ok {
 		list := strings.Split(children, ",")
 		for _, s := range list {
 			md.RequiredPackages[lower(s)] = true
 		}
 	}
 
+	md.Children = getChildren(v, utd, tn, md.PKType)
+	for _, c := range md.Children {
+		md.Preloads = append(md.Preloads, c.Field)
+	}
+	for _, r := range md.M2M {
+		md.Preloads = append(md.Preloads, r.PluralRelation)
+	}
+	for _, bt := range md.BelongsTo {
+		if bt.ParentField != "" {
+			md.Preloads = append(md.Preloads, bt.ParentField)
+		}
+	}
+
 	if _, ok := metaLookup(utd.Metadata, ROLER); ok {
 		md.DoRoler = ok
+		if o := utd.Type.ToObject(); o == nil || o["role"] == nil {
+			return md, &GenerationError{TypeName: utd.TypeName, Key: ROLER, Err: fmt.Errorf("model has no role attribute")}
+		}
 	}
 
 	if ctn, ok := metaLookup(utd.Metadata, TABLENAME); ok {
@@ -133,7 +291,42 @@ func NewModelData(version string, utd *design.UserTypeDefinition) ModelData {
 	if _, ok := metaLookup(utd.Metadata, CACHE); ok {
 		md.DoCache = ok
 	}
-	return md
+	if _, ok := metaLookup(utd.Metadata, VERSIONED); ok {
+		md.DoVersioned = ok
+		if o := utd.Type.ToObject(); o != nil && (o["version"] != nil || o["Version"] != nil) {
+			return md, &GenerationError{TypeName: utd.TypeName, Key: VERSIONED, Err: fmt.Errorf("model already has a version attribute")}
+		}
+	}
+	_, skipts := metaLookup(utd.Metadata, SKIPTS)
+	if md.DoTimestamps, err = metaSwitch(utd, TIMESTAMPS, !skipts); err != nil {
+		return md, err
+	}
+	if md.DoSoftDelete, err = metaSwitch(utd, SOFTDELETE, !skipts); err != nil {
+		return md, err
+	}
+	if _, ok := metaLookup(utd.Metadata, EAGER); ok {
+		md.DoEager = ok
+	}
+	_, novalidate := metaLookup(utd.Metadata, NOVALIDATE)
+	md.DoValidate = !novalidate
+	if hasFormatValidation(utd.Definition()) {
+		md.Imports = append(md.Imports, codegen.SimpleImport(goaImport))
+	}
+	var pkgs []string
+	for _, pk := range md.PrimaryKeys {
+		pkgs = append(pkgs, pk.Package)
+	}
+	for _, bt := range md.BelongsTo {
+		pkgs = append(pkgs, bt.KeyPackage)
+	}
+	for _, p := range md.Polymorphics {
+		pkgs = append(pkgs, p.KeyPackage)
+	}
+	for _, m := range md.M2M {
+		pkgs = append(pkgs, m.KeyPackage, m.JoinParent.KeyPackage, m.JoinChild.KeyPackage)
+	}
+	md.Imports = append(md.Imports, keyImports(pkgs...)...)
+	return md, nil
 }
 
 // NewModelWriter returns a contexts code writer.
@@ -159,12 +352,18 @@ func NewModelWriter(filename string) (*ModelWriter, error) {
 	funcMap["title"] = titleCase
 	funcMap["plural"] = plural
 	funcMap["metaLookup"] = metaLookupTmpl
-	funcMap["columns"] = GetAttributeColumns
+	funcMap["columns"] = modelColumns
+	funcMap["validations"] = modelValidations
+	funcMap["childfilter"] = childFilter
+	funcMap["childassign"] = childAssign
 	funcMap["pkattributes"] = pkAttributes
 	funcMap["pkwhere"] = pkWhere
 	funcMap["pkwherefields"] = pkWhereFields
 	funcMap["pkupdatefields"] = pkUpdateFields
-	modelTmpl, err := template.New("models").Funcs(funcMap).Parse(modelTmpl)
+	funcMap["pkname"] = pkName
+	funcMap["pkassign"] = pkAssign
+	funcMap["keyisset"] = keyIsSet
+	modelTmpl, err := template.New("model").Funcs(funcMap).Parse(modelTmpl)
 	if err != nil {
 		return nil, err
 	}
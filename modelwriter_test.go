@@ -0,0 +1,218 @@
+package gorma
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestNewModelDataVersioned(t *testing.T) {
+	name := design.Object{"name": &design.AttributeDefinition{Type: design.String}}
+	cases := []struct {
+		name string
+		meta map[string]string
+		obj  design.Object
+		want bool
+		err  string
+	}{
+		{name: "default", obj: name},
+		{name: "versioned", meta: map[string]string{VERSIONED: "true"}, obj: name, want: true},
+		{
+			name: "version attribute",
+			meta: map[string]string{VERSIONED: "true"},
+			obj:  design.Object{"version": &design.AttributeDefinition{Type: design.Integer}},
+			err:  "type UserModel, metadata #versioned: model already has a version attribute",
+		},
+	}
+	for _, c := range cases {
+		utd := newTestModel("User", c.meta)
+		utd.Type = c.obj
+		md, err := NewModelData(newTestVersion(utd), utd)
+		if err != nil || c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if md.DoVersioned != c.want {
+			t.Errorf("%s: got DoVersioned %v want %v", c.name, md.DoVersioned, c.want)
+		}
+		if got := includeVersion(&md); (got != "") != c.want {
+			t.Errorf("%s: got version field %q", c.name, got)
+		}
+	}
+}
+
+func TestNewModelDataTimestamps(t *testing.T) {
+	cases := []struct {
+		name string
+		meta map[string]string
+		want string
+		err  string
+	}{
+		{name: "default", want: "CreatedAt time.Time\nUpdatedAt time.Time\nDeletedAt *time.Time\n"},
+		{name: "skipts", meta: map[string]string{SKIPTS: "true"}},
+		{name: "no timestamps", meta: map[string]string{TIMESTAMPS: "false"}, want: "DeletedAt *time.Time\n"},
+		{name: "no softdelete", meta: map[string]string{SOFTDELETE: "false"}, want: "CreatedAt time.Time\nUpdatedAt time.Time\n"},
+		{name: "skipts with softdelete", meta: map[string]string{SKIPTS: "true", SOFTDELETE: ""}, want: "DeletedAt *time.Time\n"},
+		{
+			name: "invalid value",
+			meta: map[string]string{SOFTDELETE: "maybe"},
+			err:  `type UserModel, metadata #softdelete: invalid value "maybe", expected true or false`,
+		},
+	}
+	for _, c := range cases {
+		utd := newTestModel("User", c.meta)
+		utd.Type = design.Object{"name": &design.AttributeDefinition{Type: design.String}}
+		md, err := NewModelData(newTestVersion(utd), utd)
+		if err != nil || c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if got := includeTimeStamps(&md); got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNewModelDataPreloads(t *testing.T) {
+	cases := []struct {
+		name string
+		meta map[string]string
+		want []string
+	}{
+		{name: "none"},
+		{name: "hasmany", meta: map[string]string{HASMANY: "Proposal"}, want: []string{"Proposals"}},
+		{name: "hasone", meta: map[string]string{HASONE: "Address"}, want: []string{"Address"}},
+		{
+			name: "children and many2many",
+			meta: map[string]string{HASMANY: "Proposal", HASONE: "Address", M2M: "Industries:Industry:user_industries"},
+			want: []string{"Proposals", "Address", "Industries"},
+		},
+	}
+	for _, c := range cases {
+		user := newTestModel("User", c.meta)
+		user.Type = design.Object{"name": &design.AttributeDefinition{Type: design.String}}
+		v := newTestVersion(
+			user,
+			newTestModel("Proposal", map[string]string{BELONGSTO: "User"}),
+			newTestModel("Address", map[string]string{BELONGSTO: "User"}),
+			newTestModel("Industry", nil),
+		)
+		md, err := NewModelData(v, user)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(md.Preloads, c.want) {
+			t.Errorf("%s: got %v want %v", c.name, md.Preloads, c.want)
+		}
+	}
+}
+
+func TestModelDefUserTypes(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{"", "app.Address"},
+		{"v1", "v1.Address"},
+	}
+	for _, c := range cases {
+		address := &design.UserTypeDefinition{AttributeDefinition: &design.AttributeDefinition{Type: design.Object{}}, TypeName: "Address"}
+		user := newTestModel("User", nil)
+		user.Type = design.Object{"address": &design.AttributeDefinition{Type: address}}
+		v := newTestVersion(user)
+		v.Version = c.version
+		md, err := NewModelData(v, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		def, err := ModelDef(&md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(def, c.want) {
+			t.Errorf("%q: got\n%s\nwant the type %q", c.version, def, c.want)
+		}
+	}
+}
+
+func TestNewModelDataParents(t *testing.T) {
+	cases := []struct {
+		name string
+		user map[string]string
+		want []string
+	}{
+		{name: "parent", want: []string{"User"}},
+		{name: "parent importing the model", user: map[string]string{HASMANY: "Proposal"}},
+	}
+	for _, c := range cases {
+		proposal := newTestModel("Proposal", map[string]string{BELONGSTO: "User"})
+		proposal.Type = design.Object{"title": &design.AttributeDefinition{Type: design.String}}
+		md, err := NewModelData(newTestVersion(newTestModel("User", c.user), proposal), proposal)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(md.Preloads, c.want) {
+			t.Errorf("%s: got preloads %v want %v", c.name, md.Preloads, c.want)
+		}
+		if got, want := md.BelongsTo[0].ParentField != "", c.want != nil; got != want {
+			t.Errorf("%s: got parent field %q", c.name, md.BelongsTo[0].ParentField)
+		}
+	}
+}
+
+func TestModelTemplateUpdate(t *testing.T) {
+	cases := []struct {
+		name string
+		meta map[string]string
+		want []string
+	}{
+		{
+			name: "default",
+			want: []string{
+				"Update(ctx context.Context, o User) (error)",
+				"func (m *UserDB) Update(ctx context.Context, model User) (error) {",
+			},
+		},
+		{
+			name: "versioned",
+			meta: map[string]string{VERSIONED: "true"},
+			want: []string{
+				"Update(ctx context.Context, o User) (User, error)",
+				"func (m *UserDB) Update(ctx context.Context, model User) (User, error) {",
+				"model.Version = version\n\t\treturn model, err",
+			},
+		},
+	}
+	for _, c := range cases {
+		user := newTestModel("User", c.meta)
+		user.Type = design.Object{"name": &design.AttributeDefinition{Type: design.String}}
+		md, err := NewModelData(newTestVersion(user), user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mw, err := NewModelWriter(filepath.Join(os.TempDir(), "user_gen.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := mw.ModelTmpl.Execute(&buf, &md); err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		for _, w := range c.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("%s: missing %q in\n%s", c.name, w, buf.String())
+			}
+		}
+	}
+}
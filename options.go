@@ -0,0 +1,92 @@
+package gorma
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/raphael/goa/goagen/codegen"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// BackendGorm is the backend generating models stored with gorm.
+const BackendGorm = "gorm"
+
+// Options configures a Generator.
+type Options struct {
+	// OutputDir is the directory of the goa application, the models are
+	// generated in its "models" sub-directory.
+	OutputDir string
+	// AppPackage is the import path of the goa app package. It defaults to
+	// the "app" package of OutputDir.
+	AppPackage string
+	// ModelsPackage is the import path of the generated models directory.
+	// It defaults to the "models" package of OutputDir.
+	ModelsPackage string
+	// DesignPackage is the import path of the design package. It is used to
+	// locate the application when the import path of OutputDir can't be
+	// computed.
+	DesignPackage string
+	// Backends lists the storage backends to generate, only BackendGorm is
+	// supported. It defaults to BackendGorm.
+	Backends []string
+	// TemplatesDir is the directory holding the template overrides. A file
+	// named after a built-in template, e.g. "model.tmpl", replaces it or
+	// redefines some of its blocks.
+	TemplatesDir string
+	// DryRun renders the files in a scratch directory and reports how they
+	// differ from the files on disk instead of writing them.
+	DryRun bool
+	// Strict turns the warnings about unknown or misplaced gorma metadata
+	// keys into errors.
+	Strict bool
+}
+
+// ParseFlags maps the goagen command line flags and the gorma specific ones
+// onto generator options.
+func ParseFlags(args []string) (Options, error) {
+	var o Options
+	app := kingpin.New("Model generator", "model generator")
+	codegen.RegisterFlags(app)
+	app.Flag("dry-run", "report the changes to the generated files without writing them").BoolVar(&o.DryRun)
+	app.Flag("strict", "fail on unknown or misplaced gorma metadata keys").BoolVar(&o.Strict)
+	app.Flag("templates", "directory of the templates overriding the built-in ones").StringVar(&o.TemplatesDir)
+	app.Flag("backend", "storage backend to generate, may be repeated").StringsVar(&o.Backends)
+	if _, err := app.Parse(args); err != nil {
+		return o, err
+	}
+	o.OutputDir = codegen.OutputDir
+	o.DesignPackage = codegen.DesignPackagePath
+	return o, nil
+}
+
+// setDefaults validates the options and fills in the missing values.
+func (o *Options) setDefaults() error {
+	if o.OutputDir == "" {
+		o.OutputDir = "."
+	}
+	if len(o.Backends) == 0 {
+		o.Backends = []string{BackendGorm}
+	}
+	for _, b := range o.Backends {
+		if b != BackendGorm {
+			return fmt.Errorf("unsupported backend %q", b)
+		}
+	}
+	if o.AppPackage != "" && o.ModelsPackage != "" {
+		return nil
+	}
+	mainimp, err := importPath(o.OutputDir)
+	if err != nil {
+		if o.DesignPackage == "" {
+			return err
+		}
+		mainimp = path.Dir(o.DesignPackage)
+	}
+	if o.AppPackage == "" {
+		o.AppPackage = path.Join(mainimp, "app")
+	}
+	if o.ModelsPackage == "" {
+		o.ModelsPackage = path.Join(mainimp, "models")
+	}
+	return nil
+}
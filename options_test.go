@@ -0,0 +1,72 @@
+package gorma
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSetDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", filepath.Join(dir, "gopath"))
+	writeTestFile(t, dir, "mod/go.mod", "module example.com/app\n")
+	writeTestFile(t, dir, "elsewhere/main.go", "package main\n")
+	mod, elsewhere := filepath.Join(dir, "mod"), filepath.Join(dir, "elsewhere")
+	gorm := []string{BackendGorm}
+	cases := []struct {
+		name string
+		opts Options
+		want Options
+		err  bool
+	}{
+		{
+			name: "module",
+			opts: Options{OutputDir: mod},
+			want: Options{OutputDir: mod, AppPackage: "example.com/app/app", ModelsPackage: "example.com/app/models", Backends: gorm},
+		},
+		{
+			name: "design package",
+			opts: Options{OutputDir: elsewhere, DesignPackage: "example.com/legacy/design"},
+			want: Options{
+				OutputDir:     elsewhere,
+				AppPackage:    "example.com/legacy/app",
+				ModelsPackage: "example.com/legacy/models",
+				DesignPackage: "example.com/legacy/design",
+				Backends:      gorm,
+			},
+		},
+		{
+			name: "explicit packages",
+			opts: Options{OutputDir: elsewhere, AppPackage: "example.com/x/app", ModelsPackage: "example.com/x/db"},
+			want: Options{OutputDir: elsewhere, AppPackage: "example.com/x/app", ModelsPackage: "example.com/x/db", Backends: gorm},
+		},
+		{
+			name: "unknown import path",
+			opts: Options{OutputDir: elsewhere},
+			err:  true,
+		},
+		{
+			name: "unsupported backend",
+			opts: Options{OutputDir: mod, Backends: []string{"mongo"}},
+			err:  true,
+		},
+	}
+	for _, c := range cases {
+		opts := c.opts
+		err := opts.setDefaults()
+		if (err != nil) != c.err {
+			t.Errorf("%s: got error %v", c.name, err)
+			continue
+		}
+		if !c.err && !reflect.DeepEqual(opts, c.want) {
+			t.Errorf("%s: got %+v want %+v", c.name, opts, c.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package gorma
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Plugin customizes the code generated by gorma. Plugins are registered with
+// Generator.Register and called in registration order. Embed PluginBase to
+// only implement some of the hooks.
+type Plugin interface {
+	// Name identifies the plugin in the errors it returns.
+	Name() string
+	// Model is called with the data of each model before it is rendered.
+	// It may change the data, add imports to md.Imports or struct fields
+	// to md.Sections.
+	Model(md *ModelData) error
+	// Media is called with the data of each media type before its helpers
+	// are rendered.
+	Media(md *MediaData) error
+	// Resource is called with the data of each resource before its
+	// helpers are rendered.
+	Resource(rd *ResourceData) error
+	// Files returns the additional files to generate in the package of
+	// the model.
+	Files(md *ModelData) ([]PluginFile, error)
+}
+
+// PluginFile is a file generated by a plugin.
+type PluginFile struct {
+	// Name is the name of the file in the model package directory, e.g.
+	// "user_audit_gen.go".
+	Name string
+	// Content is the content of the file.
+	Content []byte
+}
+
+// PluginBase implements all the Plugin hooks as no-ops.
+type PluginBase struct{}
+
+// Model does nothing.
+func (PluginBase) Model(*ModelData) error { return nil }
+
+// Media does nothing.
+func (PluginBase) Media(*MediaData) error { return nil }
+
+// Resource does nothing.
+func (PluginBase) Resource(*ResourceData) error { return nil }
+
+// Files returns no file.
+func (PluginBase) Files(*ModelData) ([]PluginFile, error) { return nil, nil }
+
+// Register adds a plugin to the generator.
+func (g *Generator) Register(p Plugin) {
+	g.plugins = append(g.plugins, p)
+}
+
+// pluginError wraps an error returned by a plugin.
+func pluginError(p Plugin, err error) error {
+	if ge, ok := err.(*GenerationError); ok {
+		return ge
+	}
+	return fmt.Errorf("plugin %s: %s", p.Name(), err)
+}
+
+// modelHooks calls the Model hook of the plugins.
+func (g *Generator) modelHooks(md *ModelData) error {
+	for _, p := range g.plugins {
+		if err := p.Model(md); err != nil {
+			return pluginError(p, err)
+		}
+	}
+	return nil
+}
+
+// mediaHooks calls the Media hook of the plugins.
+func (g *Generator) mediaHooks(md *MediaData) error {
+	for _, p := range g.plugins {
+		if err := p.Media(md); err != nil {
+			return pluginError(p, err)
+		}
+	}
+	return nil
+}
+
+// resourceHooks calls the Resource hook of the plugins.
+func (g *Generator) resourceHooks(rd *ResourceData) error {
+	for _, p := range g.plugins {
+		if err := p.Resource(rd); err != nil {
+			return pluginError(p, err)
+		}
+	}
+	return nil
+}
+
+// writePluginFiles writes the files the plugins generate for a model in the
+// given directory.
+func (g *Generator) writePluginFiles(md *ModelData, dir string) error {
+	for _, p := range g.plugins {
+		files, err := p.Files(md)
+		if err != nil {
+			return pluginError(p, err)
+		}
+		for _, f := range files {
+			if f.Name == "" || f.Name == "." || f.Name == ".." || filepath.Base(f.Name) != f.Name {
+				return pluginError(p, fmt.Errorf("invalid file name %q", f.Name))
+			}
+			filename := filepath.Join(dir, f.Name)
+			out, err := g.prepareFile(filename)
+			if err != nil {
+				return err
+			}
+			g.genfiles = append(g.genfiles, filename)
+			if err := ioutil.WriteFile(out, f.Content, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package gorma
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// filesPlugin is a plugin generating files, or failing with err.
+type filesPlugin struct {
+	PluginBase
+	files []PluginFile
+	err   error
+}
+
+func (p filesPlugin) Name() string { return "files" }
+
+func (p filesPlugin) Files(md *ModelData) ([]PluginFile, error) { return p.files, p.err }
+
+func TestWritePluginFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cases := []struct {
+		name    string
+		plugins []Plugin
+		written []string
+		err     string
+	}{
+		{name: "no plugin"},
+		{
+			name: "files",
+			plugins: []Plugin{
+				filesPlugin{files: []PluginFile{{Name: "user_audit_gen.go", Content: []byte("package user\n")}}},
+				filesPlugin{files: []PluginFile{{Name: "user_cache_gen.go", Content: []byte("package user\n")}}},
+			},
+			written: []string{"user_audit_gen.go", "user_cache_gen.go"},
+		},
+		{
+			name:    "plugin error",
+			plugins: []Plugin{filesPlugin{err: errors.New("boom")}},
+			err:     "plugin files: boom",
+		},
+		{
+			name:    "generation error",
+			plugins: []Plugin{filesPlugin{err: &GenerationError{TypeName: "UserModel", Err: errors.New("boom")}}},
+			err:     "type UserModel: boom",
+		},
+		{name: "empty name", plugins: []Plugin{filesPlugin{files: []PluginFile{{}}}}, err: `plugin files: invalid file name ""`},
+		{name: "dot", plugins: []Plugin{filesPlugin{files: []PluginFile{{Name: "."}}}}, err: `plugin files: invalid file name "."`},
+		{name: "parent", plugins: []Plugin{filesPlugin{files: []PluginFile{{Name: ".."}}}}, err: `plugin files: invalid file name ".."`},
+		{
+			name:    "path",
+			plugins: []Plugin{filesPlugin{files: []PluginFile{{Name: "audit/user_audit_gen.go"}}}},
+			err:     `plugin files: invalid file name "audit/user_audit_gen.go"`,
+		},
+		{
+			name:    "escaping path",
+			plugins: []Plugin{filesPlugin{files: []PluginFile{{Name: "../user_audit_gen.go"}}}},
+			err:     `plugin files: invalid file name "../user_audit_gen.go"`,
+		},
+	}
+	for _, c := range cases {
+		out := filepath.Join(dir, c.name)
+		g := &Generator{Options: Options{OutputDir: out}}
+		for _, p := range c.plugins {
+			g.Register(p)
+		}
+		err := g.writePluginFiles(&ModelData{TypeName: "User"}, out)
+		switch {
+		case c.err != "":
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+			continue
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		var written []string
+		for _, f := range c.written {
+			written = append(written, filepath.Join(out, f))
+			if b, err := ioutil.ReadFile(filepath.Join(out, f)); err != nil || string(b) != "package user\n" {
+				t.Errorf("%s: %s holds %q, %v", c.name, f, b, err)
+			}
+		}
+		if !reflect.DeepEqual(g.genfiles, written) {
+			t.Errorf("%s: recorded %v want %v", c.name, g.genfiles, written)
+		}
+	}
+}
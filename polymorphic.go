@@ -0,0 +1,68 @@
+package gorma
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// Polymorphic is a relation declared with the "#polymorphic" metadata of a
+// model: the model belongs to parents of different kinds, identified by the
+// <Name>ID and <Name>Type columns.
+type Polymorphic struct {
+	// Name is the name of the relation, e.g. "Owner".
+	Name string
+	// KeyType is the Go type of the <Name>ID field.
+	KeyType string
+	// KeyPackage is the import path of the package of KeyType, empty for
+	// builtin types.
+	KeyPackage string
+}
+
+// getPolymorphics parses the "#polymorphic" metadata of a model. Its value
+// lists the relations separated by commas, each made of the name of the
+// relation optionally followed by a colon and the type of the parent keys,
+// e.g. "Owner" or "Owner:string". Keys are int by default.
+func getPolymorphics(utd *design.UserTypeDefinition) ([]Polymorphic, error) {
+	var polys []Polymorphic
+	for _, entry := range metaList(utd.Metadata, POLYMORPHIC) {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		p := Polymorphic{Name: codegen.Goify(parts[0], true), KeyType: "int"}
+		if len(parts) == 2 {
+			p.KeyType = strings.TrimSpace(parts[1])
+		}
+		if parts[0] == "" || p.KeyType == "" {
+			return nil, &GenerationError{TypeName: utd.TypeName, Key: POLYMORPHIC, Err: fmt.Errorf("malformed entry %q, expected Name or Name:keytype", entry)}
+		}
+		var err error
+		if p.KeyType, p.KeyPackage, err = parseKeyType(p.KeyType); err != nil {
+			return nil, &GenerationError{TypeName: utd.TypeName, Key: POLYMORPHIC, Err: fmt.Errorf("%s in %q", err, entry)}
+		}
+		polys = append(polys, p)
+	}
+	return polys, nil
+}
+
+// polymorphicRelation returns the relation through which the child model
+// belongs to the parents listing it in their "#hasmany" or "#hasone"
+// metadata: its only polymorphic relation. ok is false if the child has no
+// polymorphic relation or more than one.
+func polymorphicRelation(child *design.UserTypeDefinition) (p Polymorphic, ok bool) {
+	polys, err := getPolymorphics(child)
+	if err != nil || len(polys) != 1 {
+		return p, false
+	}
+	return polys[0], true
+}
+
+// includePolymorphic returns the ID and type fields of the polymorphic
+// relations of a model.
+func includePolymorphic(md *ModelData) string {
+	var fields string
+	for _, p := range md.Polymorphics {
+		fields += fmt.Sprintf("%sID %s\n%sType string\n", p.Name, p.KeyType, p.Name)
+	}
+	return fields
+}
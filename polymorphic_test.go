@@ -0,0 +1,82 @@
+package gorma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetPolymorphics(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  []Polymorphic
+		err   string
+	}{
+		{name: "none"},
+		{
+			name:  "default key type",
+			value: "owner",
+			want:  []Polymorphic{{Name: "Owner", KeyType: "int"}},
+		},
+		{
+			name:  "key types and spaces",
+			value: " Owner : string , Subject:int64",
+			want:  []Polymorphic{{Name: "Owner", KeyType: "string"}, {Name: "Subject", KeyType: "int64"}},
+		},
+		{
+			name:  "package qualified key type",
+			value: "Owner:github.com/google/uuid.UUID",
+			want:  []Polymorphic{{Name: "Owner", KeyType: "uuid.UUID", KeyPackage: "github.com/google/uuid"}},
+		},
+		{name: "empty name", value: ":string", err: `malformed entry ":string", expected Name or Name:keytype`},
+		{name: "empty key type", value: "Owner:", err: `malformed entry "Owner:"`},
+		{name: "empty entry", value: "Owner,", err: `malformed entry ""`},
+		{name: "unknown key type", value: "Owner:integer", err: `unknown key type "integer"`},
+		{name: "too many parts", value: "Owner:int:null", err: `unknown key type "int:null"`},
+	}
+	for _, c := range cases {
+		meta := map[string]string{}
+		if c.value != "" {
+			meta[POLYMORPHIC] = c.value
+		}
+		got, err := getPolymorphics(newTestModel("Comment", meta))
+		switch {
+		case c.err != "":
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case !reflect.DeepEqual(got, c.want):
+			t.Errorf("%s: got %+v want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPolymorphicRelation(t *testing.T) {
+	cases := []struct {
+		value string
+		want  Polymorphic
+		ok    bool
+	}{
+		{"", Polymorphic{}, false},
+		{"Owner:string", Polymorphic{Name: "Owner", KeyType: "string"}, true},
+		{"Owner,Subject", Polymorphic{}, false},
+		{":string", Polymorphic{}, false},
+	}
+	for _, c := range cases {
+		got, ok := polymorphicRelation(newTestModel("Comment", map[string]string{POLYMORPHIC: c.value}))
+		if ok != c.ok || got != c.want {
+			t.Errorf("%q: got %+v, %v want %+v, %v", c.value, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestIncludePolymorphic(t *testing.T) {
+	md := &ModelData{Polymorphics: []Polymorphic{{Name: "Owner", KeyType: "int"}, {Name: "Subject", KeyType: "string"}}}
+	want := "OwnerID int\nOwnerType string\nSubjectID string\nSubjectType string\n"
+	if got := includePolymorphic(md); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
@@ -37,4 +37,5 @@ func init() {
 		{{end}}{{end}}
 	}, []string{USER})
 }
+{{ block "extra" . }}{{ end }}
 `
@@ -11,9 +11,15 @@ func {{$typename}}From{{version $version}}{{title $action.Name}}Payload(ctx *{{$
 	payload := ctx.Payload
 	m := {{$typename}}{}
 	copier.Copy(&m, payload)
-{{ range $idx, $bt := $belongs }}
-	m.{{ $bt.Parent}}ID=int(ctx.{{ $bt.Parent}}ID){{end}}
+	{{ enumconv $.Enums $action.Payload.AttributeDefinition "m" "payload" true }}
+{{ range $idx, $bt := $belongs }}{{ if $bt.Nullable }}
+	{
+		v := {{ $bt.KeyType}}(ctx.{{ $bt.Role}}ID)
+		m.{{ $bt.Role}}ID = &v
+	}{{ else }}
+	m.{{ $bt.Role}}ID={{ $bt.KeyType}}(ctx.{{ $bt.Role}}ID){{ end }}{{end}}
 	return m
 }
 {{ end }}{{end}}{{end}}
+{{ block "extra" . }}{{ end }}
 `
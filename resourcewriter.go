@@ -1,8 +1,6 @@
 package gorma
 
 import (
-	"fmt"
-	"strings"
 	"text/template"
 
 	"github.com/raphael/goa/design"
@@ -15,46 +13,55 @@ type ResourceWriter struct {
 	*codegen.GoGenerator
 	ResourceTmpl *template.Template
 }
+
+// ResourceData is the data the resource template is rendered with.
 type ResourceData struct {
-	TypeDef          *design.ResourceDefinition
-	TypeName         string
-	MediaUpper       string
-	MediaLower       string
-	BelongsTo        []BelongsTo
-	DoMedia          bool
-	APIVersion       string
+	// TypeDef is the resource the payload helpers are generated for.
+	TypeDef *design.ResourceDefinition
+	// TypeName is the name of the model built from the payloads.
+	TypeName   string
+	MediaUpper string
+	MediaLower string
+	BelongsTo  []BelongsTo
+	// DoMedia is false when the resource is tagged with "#nomedia".
+	DoMedia bool
+	// APIVersion is the name of the goa app package of the API version.
+	APIVersion string
+	// Enums lists the enum types of the model.
+	Enums []Enum
+	// RequiredPackages lists the model packages the helpers import.
 	RequiredPackages map[string]bool
+	// Imports lists additional imports of the generated file.
+	Imports []*codegen.ImportSpec
 }
 
-func NewResourceData(version string, utd *design.ResourceDefinition) ResourceData {
+// NewResourceData returns the data used to render the payload helpers
+// generated for the given resource.
+func NewResourceData(v *design.APIVersionDefinition, utd *design.ResourceDefinition) ResourceData {
 	md := ResourceData{
 		TypeDef:          utd,
 		RequiredPackages: make(map[string]bool, 0),
 	}
 	md.TypeName = codegen.Goify(utd.Name, true)
+	if model := lookupModel(v, md.TypeName); model != nil {
+		md.Enums = getEnums(md.TypeName, model.Definition())
+	}
 	md.MediaUpper = upper(utd.Name)
 	md.MediaLower = lower(utd.Name)
-	if version != "" {
-		md.APIVersion = codegen.VersionPackage(version)
+	if v.Version != "" {
+		md.APIVersion = codegen.VersionPackage(v.Version)
 	} else {
 		// import the default package instead of nothing
 		md.APIVersion = "app"
 	}
 
-	var belongs []BelongsTo
-	if bt, ok := metaLookup(utd.Metadata, BELONGSTO); ok {
-		btlist := strings.Split(bt, ",")
-		for _, s := range btlist {
-			binst := BelongsTo{
-				Parent:        s,
-				DatabaseField: camelToSnake(s),
-			}
-			belongs = append(belongs, binst)
-
-			md.RequiredPackages[lower(s)] = true
-		}
+	md.BelongsTo = helperBelongsTo(v, utd.Name, utd.Metadata)
+	var pkgs []string
+	for _, bt := range md.BelongsTo {
+		md.RequiredPackages[lower(bt.Parent)] = true
+		pkgs = append(pkgs, bt.KeyPackage)
 	}
-	md.BelongsTo = belongs
+	md.Imports = keyImports(pkgs...)
 	md.DoMedia = true
 	if _, ok := metaLookup(utd.Metadata, MEDIA); ok {
 		md.DoMedia = !ok
@@ -88,9 +95,10 @@ func NewResourceWriter(filename string) (*ResourceWriter, error) {
 	funcMap["metaLookup"] = metaLookupTmpl
 	funcMap["columns"] = GetAttributeColumns
 	funcMap["version"] = versionize
+	funcMap["enumconv"] = enumConversions
 	funcMap["hasusertype"] = hasUserType
 
-	modelTmpl, err := template.New("media").Funcs(funcMap).Parse(resourceTmpl)
+	modelTmpl, err := template.New("resource").Funcs(funcMap).Parse(resourceTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -103,9 +111,5 @@ func NewResourceWriter(filename string) (*ResourceWriter, error) {
 
 // Execute writes the code for the context types to the writer.
 func (w *ResourceWriter) Execute(rd *ResourceData) error {
-	err := w.ResourceTmpl.Execute(w, rd)
-	if err != nil {
-		fmt.Println("Error executing template", err.Error())
-	}
-	return err
+	return w.ResourceTmpl.Execute(w, rd)
 }
@@ -0,0 +1,32 @@
+package gorma
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// overrideTemplate parses the file of the templates directory named after the
+// built-in template t, e.g. "model.tmpl", into t. A file with a body replaces
+// the built-in template while {{define}} blocks replace the blocks of the same
+// name, e.g. "one" or "extra". The funcMap of t is available to the file. A
+// missing directory or file leaves t unchanged.
+func overrideTemplate(t *template.Template, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	filename := filepath.Join(dir, t.Name()+".tmpl")
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := t.Parse(string(content)); err != nil {
+		return fmt.Errorf("template override %s: %s", filename, err)
+	}
+	return nil
+}
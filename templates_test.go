@@ -0,0 +1,81 @@
+package gorma
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestOverrideTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cases := []struct {
+		name     string
+		override string
+		want     string
+		err      string
+	}{
+		{name: "no file", want: "A one B"},
+		{name: "blocks only", override: `{{ define "one" }}ONE {{ lower . }}{{ end }}`, want: "A ONE x B"},
+		{name: "blocks on lines", override: "{{ define \"one\" }}1{{ end }}\n\n{{ define \"two\" }}2{{ end }}\n", want: "A 1 B"},
+		{name: "body", override: `replaced {{ . }}`, want: "replaced X"},
+		{name: "body and blocks", override: `R{{ template "one" . }}{{ define "one" }}1{{ end }}`, want: "R1"},
+		{name: "unknown function", override: `{{ define "one" }}{{ bogus }}{{ end }}`, err: `model:1: function "bogus" not defined`},
+		{name: "syntax error", override: `{{ if }}`, err: "template override " + filepath.Join(dir, "model.tmpl")},
+	}
+	for _, c := range cases {
+		os.Remove(filepath.Join(dir, "model.tmpl"))
+		if c.override != "" {
+			writeTestFile(t, dir, "model.tmpl", c.override)
+		}
+		tmpl := template.Must(template.New("model").Funcs(template.FuncMap{"lower": strings.ToLower}).Parse(`A {{ block "one" . }}one{{ end }} B`))
+		err := overrideTemplate(tmpl, dir)
+		switch {
+		case c.err != "":
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v want %q", c.name, err, c.err)
+			}
+			continue
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, "X"); err != nil {
+			t.Errorf("%s: %s", c.name, err)
+		} else if got := buf.String(); got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestOverrideTemplateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "model.tmpl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		dir string
+		err bool
+	}{
+		{"", false},
+		{filepath.Join(dir, "missing"), false},
+		{dir, true},
+	} {
+		tmpl := template.Must(template.New("model").Parse("A"))
+		if err := overrideTemplate(tmpl, c.dir); (err != nil) != c.err {
+			t.Errorf("%q: got error %v", c.dir, err)
+		}
+	}
+}
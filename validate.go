@@ -0,0 +1,376 @@
+package gorma
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qor/inflection"
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// relation is an edge of the graph of imports between model packages.
+type relation struct {
+	key   string
+	child string
+}
+
+// validateRelations checks the relationship metadata of the models defined in
+// an API version before any code gets rendered: referenced models must exist
+// and be gorma models, models whose key a relation holds must have a single
+// primary key, many2many entries must be well formed and declared on one side
+// only, inverse relations, either belongsto or polymorphic, must agree and
+// model packages must not import each other.
+func validateRelations(v *design.APIVersionDefinition) GenerationErrors {
+	var errs GenerationErrors
+	types := make(map[string]*design.UserTypeDefinition)
+	models := make(map[string]*design.UserTypeDefinition)
+	var names []string
+	v.IterateUserTypes(func(utd *design.UserTypeDefinition) error {
+		name := modelName(utd)
+		types[name] = utd
+		if modelMetadata(utd.Definition()) {
+			models[name] = utd
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	compound := make(map[string]bool)
+	for _, name := range names {
+		if pks, err := getPrimaryKeys(models[name]); err == nil && len(pks) > 1 {
+			compound[name] = true
+		}
+	}
+
+	// checkKey reports relations referencing a model with a compound primary
+	// key, a single foreign key column can't hold its key.
+	checkKey := func(utd *design.UserTypeDefinition, key, model string) bool {
+		if !compound[model] {
+			return true
+		}
+		errs = append(errs, &GenerationError{
+			TypeName: utd.TypeName,
+			Key:      key,
+			Err:      fmt.Errorf("model %q has a compound primary key, relations referencing it are not supported", model),
+		})
+		return false
+	}
+
+	// checkTarget reports relations to types that are not gorma models.
+	checkTarget := func(utd *design.UserTypeDefinition, key, target string) bool {
+		if _, ok := models[target]; ok {
+			return true
+		}
+		msg := fmt.Sprintf("unknown model %q", target)
+		if _, ok := types[target]; ok {
+			msg = fmt.Sprintf("type %q is not a gorma model, it needs the %q metadata", target, META_NAMESPACE)
+		}
+		errs = append(errs, &GenerationError{TypeName: utd.TypeName, Key: key, Err: errors.New(msg)})
+		return false
+	}
+
+	imports := make(map[string][]relation)
+	for _, name := range names {
+		utd := models[name]
+		if _, err := parseBelongsTo(v, utd.TypeName, utd.Metadata); err != nil {
+			errs = append(errs, err.(*GenerationError))
+		}
+		for _, parent := range belongsToParents(utd.Metadata) {
+			if checkTarget(utd, BELONGSTO, parent) {
+				checkKey(utd, BELONGSTO, parent)
+			}
+		}
+		for _, key := range []string{HASMANY, HASONE} {
+			if len(metaList(utd.Metadata, key)) > 0 && !checkKey(utd, key, name) {
+				continue
+			}
+			for _, child := range metaList(utd.Metadata, key) {
+				if !checkTarget(utd, key, child) {
+					continue
+				}
+				imports[name] = append(imports[name], relation{key: key, child: child})
+				if hasString(belongsToParents(models[child].Metadata), name) {
+					continue
+				}
+				if _, ok := polymorphicRelation(models[child]); ok {
+					continue
+				}
+				err := fmt.Errorf("model %q does not declare %s %q", child, BELONGSTO, name)
+				if len(metaList(models[child].Metadata, POLYMORPHIC)) > 1 {
+					err = fmt.Errorf("model %q has more than one %s relation and does not declare %s %q", child, POLYMORPHIC, BELONGSTO, name)
+				}
+				errs = append(errs, &GenerationError{TypeName: utd.TypeName, Key: key, Err: err})
+			}
+		}
+		for _, entry := range metaList(utd.Metadata, M2M) {
+			parts := strings.Split(entry, ":")
+			if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" || len(parts) == 4 && parts[3] == "" {
+				errs = append(errs, &GenerationError{
+					TypeName: utd.TypeName,
+					Key:      M2M,
+					Err:      fmt.Errorf("malformed entry %q, expected PluralModel:SingularModel:join_table_name[:JoinModel]", entry),
+				})
+				continue
+			}
+			if !checkTarget(utd, M2M, parts[1]) || !checkKey(utd, M2M, parts[1]) {
+				continue
+			}
+			if parts[1] != name && declaresM2M(models[parts[1]], name) {
+				// the inverse declaration would make the model packages
+				// import each other, report it once for the pair.
+				if name < parts[1] {
+					errs = append(errs, &GenerationError{
+						TypeName: utd.TypeName,
+						Key:      M2M,
+						Err:      fmt.Errorf("model %q also declares %s %q, declare many2many relations on one side only", parts[1], M2M, name),
+					})
+				}
+				continue
+			}
+			imports[name] = append(imports[name], relation{key: M2M, child: parts[1]})
+			if len(parts) == 4 && checkTarget(utd, M2M, parts[3]) {
+				imports[name] = append(imports[name], relation{key: M2M, child: parts[3]})
+				if _, _, err := joinRelations(v, models[parts[3]], name, parts[1]); err != nil {
+					errs = append(errs, &GenerationError{TypeName: utd.TypeName, Key: M2M, Err: err})
+				}
+				if table := tableName(models[parts[3]]); table != parts[2] {
+					errs = append(errs, &GenerationError{
+						TypeName: utd.TypeName,
+						Key:      M2M,
+						Err:      fmt.Errorf("join table %q differs from the table %q of join model %q", parts[2], table, parts[3]),
+					})
+				}
+			}
+		}
+	}
+
+	for _, cycle := range importCycles(names, imports) {
+		path := make([]string, len(cycle))
+		for i, r := range cycle {
+			path[i] = lower(r.child)
+		}
+		from := cycle[len(cycle)-1].child
+		errs = append(errs, &GenerationError{
+			TypeName: models[from].TypeName,
+			Key:      cycle[0].key,
+			Err:      fmt.Errorf("relations form an import cycle between model packages: %s -> %s", lower(from), strings.Join(path, " -> ")),
+		})
+	}
+	return errs
+}
+
+// declaresM2M returns true if the model utd declares a many2many relation to
+// the model called name.
+func declaresM2M(utd *design.UserTypeDefinition, name string) bool {
+	for _, entry := range metaList(utd.Metadata, M2M) {
+		if parts := strings.Split(entry, ":"); len(parts) >= 3 && parts[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// importCycles returns the cycles of the model package import graph. A cycle
+// is the list of relations followed from its first model back to it.
+func importCycles(names []string, imports map[string][]relation) [][]relation {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var cycles [][]relation
+	seen := make(map[string]bool)
+	state := make(map[string]int)
+	// path holds the models being visited, edges[i] leads from path[i]
+	var path []string
+	var edges []relation
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+		for _, r := range imports[name] {
+			switch state[r.child] {
+			case visiting:
+				idx := len(path) - 1
+				for path[idx] != r.child {
+					idx--
+				}
+				cycle := rotateCycle(append(append([]relation{}, edges[idx:]...), r))
+				var id []string
+				for _, c := range cycle {
+					id = append(id, c.child)
+				}
+				if k := strings.Join(id, ","); !seen[k] {
+					seen[k] = true
+					cycles = append(cycles, cycle)
+				}
+			case unvisited:
+				edges = append(edges, r)
+				visit(r.child)
+				edges = edges[:len(edges)-1]
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// rotateCycle rotates a cycle so that it starts from its smallest model name,
+// that is so that its last relation leads to that model.
+func rotateCycle(cycle []relation) []relation {
+	min := 0
+	for i, r := range cycle {
+		if r.child < cycle[min].child {
+			min = i
+		}
+	}
+	return append(append([]relation{}, cycle[min+1:]...), cycle[:min+1]...)
+}
+
+// modelName returns the name used to reference a model in the relationship
+// metadata.
+func modelName(utd *design.UserTypeDefinition) string {
+	return deModel(codegen.GoTypeName(utd, 0))
+}
+
+// tableName returns the name of the table of a model: its "#tablename"
+// metadata or the name gorm derives from the model name.
+func tableName(utd *design.UserTypeDefinition) string {
+	if val, ok := metaLookup(utd.Metadata, TABLENAME); ok {
+		return val
+	}
+	return inflection.Plural(camelToSnake(modelName(utd)))
+}
+
+// metaList returns the comma separated values of a gorma metadata key.
+func metaList(md design.MetadataDefinition, hashtag string) []string {
+	val, ok := metaLookup(md, hashtag)
+	if !ok {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
+// hasString returns true if list contains s.
+func hasString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMetadata checks the gorma metadata keys used in an API version
+// against the registry of supported keys. It reports unknown keys, suggesting
+// the closest supported one, and keys used outside of their scope.
+func validateMetadata(v *design.APIVersionDefinition) GenerationErrors {
+	var errs GenerationErrors
+	check := func(md design.MetadataDefinition, scope metaScope, typeName, attName string) {
+		var keys []string
+		for k := range md {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if len(k) < len(META_NAMESPACE) || !strings.EqualFold(k[:len(META_NAMESPACE)], META_NAMESPACE) {
+				continue
+			}
+			hashtag := k[len(META_NAMESPACE):]
+			if hashtag != "" && hashtag[0] != '#' {
+				continue
+			}
+			e := &GenerationError{TypeName: typeName, Attribute: attName, Key: hashtag}
+			if scopes, ok := metaKeys[strings.ToLower(hashtag)]; !ok {
+				msg := "unknown gorma metadata key"
+				if s := closestMetaKey(hashtag); s != "" {
+					msg += fmt.Sprintf(", did you mean %q?", s)
+				}
+				e.Err = errors.New(msg)
+			} else if scopes&scope == 0 {
+				e.Err = fmt.Errorf("key does not apply to %s, only to %s", scope, scopes)
+			} else {
+				continue
+			}
+			if !errs.contains(e) {
+				errs = append(errs, e)
+			}
+		}
+	}
+	check(v.Metadata, apiScope, "", "")
+	v.IterateUserTypes(func(utd *design.UserTypeDefinition) error {
+		check(utd.Metadata, modelScope, utd.TypeName, "")
+		if o := utd.Type.ToObject(); o != nil {
+			o.IterateAttributes(func(n string, att *design.AttributeDefinition) error {
+				check(att.Metadata, attributeScope, utd.TypeName, n)
+				return nil
+			})
+		}
+		return nil
+	})
+	v.IterateMediaTypes(func(mt *design.MediaTypeDefinition) error {
+		check(mt.Metadata, mediaScope, mt.TypeName, "")
+		return nil
+	})
+	v.IterateResources(func(res *design.ResourceDefinition) error {
+		check(res.Metadata, mediaScope, res.Name, "")
+		return nil
+	})
+	return errs
+}
+
+// closestMetaKey returns the supported metadata key closest to hashtag or the
+// empty string if none is close enough to be a likely misspelling.
+func closestMetaKey(hashtag string) string {
+	hashtag = strings.ToLower(hashtag)
+	var keys []string
+	for k := range metaKeys {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	best, dist := "", len(hashtag)/3+1
+	for _, k := range keys {
+		if d := levenshtein(hashtag, k); d <= dist {
+			best, dist = k, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j] + 1
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,377 @@
+package gorma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+// newTestModel returns a gorma model called name + "Model" with the given
+// gorma metadata, keyed without namespace, e.g. "#belongsto".
+func newTestModel(name string, meta map[string]string) *design.UserTypeDefinition {
+	md := design.MetadataDefinition{META_NAMESPACE: "Model"}
+	for k, v := range meta {
+		md[META_NAMESPACE+k] = v
+	}
+	return &design.UserTypeDefinition{
+		AttributeDefinition: &design.AttributeDefinition{Type: design.Object{}, Metadata: md},
+		TypeName:            name + "Model",
+	}
+}
+
+// newTestVersion returns an API version defining the given types.
+func newTestVersion(types ...*design.UserTypeDefinition) *design.APIVersionDefinition {
+	v := &design.APIVersionDefinition{Types: make(map[string]*design.UserTypeDefinition)}
+	for _, t := range types {
+		v.Types[t.TypeName] = t
+	}
+	return v
+}
+
+// cycleChildren returns the models the relations of each cycle lead to.
+func cycleChildren(cycles [][]relation) [][]string {
+	var res [][]string
+	for _, c := range cycles {
+		var children []string
+		for _, r := range c {
+			children = append(children, r.child)
+		}
+		res = append(res, children)
+	}
+	return res
+}
+
+func TestImportCycles(t *testing.T) {
+	cases := []struct {
+		name    string
+		names   []string
+		imports map[string][]relation
+		want    [][]string
+	}{
+		{
+			name:  "two models",
+			names: []string{"A", "B"},
+			imports: map[string][]relation{
+				"A": {{HASMANY, "B"}},
+				"B": {{HASONE, "A"}},
+			},
+			want: [][]string{{"B", "A"}},
+		},
+		{
+			name:  "three models",
+			names: []string{"A", "B", "C"},
+			imports: map[string][]relation{
+				"A": {{HASMANY, "B"}},
+				"B": {{HASMANY, "C"}},
+				"C": {{M2M, "A"}},
+			},
+			want: [][]string{{"B", "C", "A"}},
+		},
+		{
+			name:    "self reference",
+			names:   []string{"A"},
+			imports: map[string][]relation{"A": {{HASMANY, "A"}}},
+			want:    [][]string{{"A"}},
+		},
+		{
+			name:  "started from another model",
+			names: []string{"C", "B", "A"},
+			imports: map[string][]relation{
+				"A": {{HASMANY, "B"}},
+				"B": {{HASMANY, "C"}},
+				"C": {{M2M, "A"}},
+			},
+			want: [][]string{{"B", "C", "A"}},
+		},
+		{
+			name:  "same cycle through several relations",
+			names: []string{"B", "A"},
+			imports: map[string][]relation{
+				"A": {{HASMANY, "B"}, {M2M, "B"}},
+				"B": {{HASONE, "A"}},
+			},
+			want: [][]string{{"B", "A"}},
+		},
+		{
+			name:  "no cycle",
+			names: []string{"A", "B", "C"},
+			imports: map[string][]relation{
+				"A": {{HASMANY, "B"}, {HASMANY, "C"}},
+				"B": {{HASMANY, "C"}},
+			},
+		},
+	}
+	for _, c := range cases {
+		if got := cycleChildren(importCycles(c.names, c.imports)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestValidateRelationsCycles(t *testing.T) {
+	cases := []struct {
+		name   string
+		models []*design.UserTypeDefinition
+		want   string
+	}{
+		{
+			name: "two models",
+			models: []*design.UserTypeDefinition{
+				newTestModel("A", map[string]string{HASMANY: "B", BELONGSTO: "B"}),
+				newTestModel("B", map[string]string{HASMANY: "A", BELONGSTO: "A"}),
+			},
+			want: "type AModel, metadata #hasmany: relations form an import cycle between model packages: a -> b -> a",
+		},
+		{
+			name: "three models",
+			models: []*design.UserTypeDefinition{
+				newTestModel("A", map[string]string{HASMANY: "B", BELONGSTO: "C"}),
+				newTestModel("B", map[string]string{HASMANY: "C", BELONGSTO: "A"}),
+				newTestModel("C", map[string]string{HASMANY: "A", BELONGSTO: "B"}),
+			},
+			want: "type AModel, metadata #hasmany: relations form an import cycle between model packages: a -> b -> c -> a",
+		},
+		{
+			name: "self reference",
+			models: []*design.UserTypeDefinition{
+				newTestModel("A", map[string]string{HASMANY: "A", BELONGSTO: "A"}),
+			},
+			want: "type AModel, metadata #hasmany: relations form an import cycle between model packages: a -> a",
+		},
+	}
+	for _, c := range cases {
+		errs := validateRelations(newTestVersion(c.models...))
+		if len(errs) != 1 || errs[0].Error() != c.want {
+			t.Errorf("%s: got %v want %q", c.name, errs, c.want)
+		}
+	}
+}
+
+func TestValidateRelations(t *testing.T) {
+	// Account has a compound primary key.
+	account := func(meta map[string]string) *design.UserTypeDefinition {
+		utd := newTestModel("Account", meta)
+		key := design.MetadataDefinition{META_NAMESPACE + GORMTAG: "primary_key"}
+		utd.Type = design.Object{
+			"org_id": &design.AttributeDefinition{Type: design.Integer, Metadata: key},
+			"number": &design.AttributeDefinition{Type: design.Integer, Metadata: key},
+		}
+		return utd
+	}
+	cases := []struct {
+		name   string
+		models []*design.UserTypeDefinition
+		want   string
+	}{
+		{
+			name: "valid",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{HASMANY: "Proposal"}),
+				newTestModel("Proposal", map[string]string{BELONGSTO: "User"}),
+			},
+		},
+		{
+			name: "unknown model",
+			models: []*design.UserTypeDefinition{
+				newTestModel("Proposal", map[string]string{BELONGSTO: "User"}),
+			},
+			want: `unknown model "User"`,
+		},
+		{
+			name: "missing inverse relation",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{HASMANY: "Proposal"}),
+				newTestModel("Proposal", nil),
+			},
+			want: `model "Proposal" does not declare #belongsto "User"`,
+		},
+		{
+			name: "many2many",
+			models: []*design.UserTypeDefinition{
+				newTestModel("Company", map[string]string{M2M: "Industries:Industry:company_industries"}),
+				newTestModel("Industry", nil),
+			},
+		},
+		{
+			name: "many2many declared on both sides",
+			models: []*design.UserTypeDefinition{
+				newTestModel("Company", map[string]string{M2M: "Industries:Industry:company_industries"}),
+				newTestModel("Industry", map[string]string{M2M: "Companies:Company:company_industries"}),
+			},
+			want: `model "Industry" also declares #many2many "Company", declare many2many relations on one side only`,
+		},
+		{
+			name: "join model",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{M2M: "Roles:Role:user_roles:UserRole"}),
+				newTestModel("Role", nil),
+				newTestModel("UserRole", map[string]string{BELONGSTO: "User,Role"}),
+			},
+		},
+		{
+			name: "join model table name",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{M2M: "Roles:Role:grants:UserRole"}),
+				newTestModel("Role", nil),
+				newTestModel("UserRole", map[string]string{BELONGSTO: "User,Role", TABLENAME: "grants"}),
+			},
+		},
+		{
+			name: "join model in another table",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{M2M: "Roles:Role:grants:UserRole"}),
+				newTestModel("Role", nil),
+				newTestModel("UserRole", map[string]string{BELONGSTO: "User,Role"}),
+			},
+			want: `join table "grants" differs from the table "user_roles" of join model "UserRole"`,
+		},
+		{
+			name: "join model without relation",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{M2M: "Roles:Role:user_roles:UserRole"}),
+				newTestModel("Role", nil),
+				newTestModel("UserRole", map[string]string{BELONGSTO: "User"}),
+			},
+			want: `join model "UserRole" does not declare #belongsto "Role"`,
+		},
+		{
+			name: "unknown join model",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{M2M: "Roles:Role:user_roles:UserRole"}),
+				newTestModel("Role", nil),
+			},
+			want: `unknown model "UserRole"`,
+		},
+		{
+			name: "malformed many2many",
+			models: []*design.UserTypeDefinition{
+				newTestModel("User", map[string]string{M2M: "Roles:Role:user_roles:"}),
+				newTestModel("Role", nil),
+			},
+			want: `malformed entry "Roles:Role:user_roles:"`,
+		},
+		{
+			name: "belongsto compound key",
+			models: []*design.UserTypeDefinition{
+				account(nil),
+				newTestModel("Invoice", map[string]string{BELONGSTO: "Account"}),
+			},
+			want: `type InvoiceModel, metadata #belongsto: model "Account" has a compound primary key, relations referencing it are not supported`,
+		},
+		{
+			name: "many2many to compound key",
+			models: []*design.UserTypeDefinition{
+				account(nil),
+				newTestModel("User", map[string]string{M2M: "Accounts:Account:user_accounts"}),
+			},
+			want: `type UserModel, metadata #many2many: model "Account" has a compound primary key`,
+		},
+		{
+			name: "many2many from compound key",
+			models: []*design.UserTypeDefinition{
+				account(map[string]string{M2M: "Users:User:user_accounts"}),
+				newTestModel("User", nil),
+			},
+		},
+		{
+			name: "hasmany from compound key",
+			models: []*design.UserTypeDefinition{
+				account(map[string]string{HASMANY: "Comment"}),
+				newTestModel("Comment", map[string]string{POLYMORPHIC: "Commentable"}),
+			},
+			want: `type AccountModel, metadata #hasmany: model "Account" has a compound primary key`,
+		},
+	}
+	for _, c := range cases {
+		errs := validateRelations(newTestVersion(c.models...))
+		switch {
+		case c.want == "" && len(errs) > 0:
+			t.Errorf("%s: unexpected %v", c.name, errs)
+		case c.want != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), c.want)):
+			t.Errorf("%s: got %v want %q", c.name, errs, c.want)
+		}
+	}
+}
+
+func TestTableName(t *testing.T) {
+	cases := []struct {
+		model string
+		meta  map[string]string
+		want  string
+	}{
+		{"User", nil, "users"},
+		{"UserRole", nil, "user_roles"},
+		{"UserRole", map[string]string{TABLENAME: "grants"}, "grants"},
+	}
+	for _, c := range cases {
+		if got := tableName(newTestModel(c.model, c.meta)); got != c.want {
+			t.Errorf("%s %v: got %q want %q", c.model, c.meta, got, c.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"flaw", "lawn", 2},
+		{"kitten", "sitting", 3},
+		{"#belongto", "#belongsto", 1},
+	}
+	for _, c := range cases {
+		if got := levenshtein(c.a, c.b); got != c.want {
+			t.Errorf("levenshtein(%q, %q): got %d want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestClosestMetaKey(t *testing.T) {
+	cases := []struct {
+		hashtag string
+		want    string
+	}{
+		{"#belongTo", BELONGSTO},
+		{"#HasMany", HASMANY},
+		{"#hasmay", HASMANY},
+		{"#pktyp", PKTYPE},
+		{"#idx", INDEX},
+		{"#ix", ""},
+		{"#frobnicate", ""},
+	}
+	for _, c := range cases {
+		if got := closestMetaKey(c.hashtag); got != c.want {
+			t.Errorf("%s: got %q want %q", c.hashtag, got, c.want)
+		}
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	user := newTestModel("User", map[string]string{GORMTAG: "column:x"})
+	user.Type = design.Object{"name": &design.AttributeDefinition{
+		Type:     design.String,
+		Metadata: design.MetadataDefinition{META_NAMESPACE + HASMANY: "Proposal", META_NAMESPACE + SQLTAG: "size:20"},
+	}}
+	proposal := newTestModel("Proposal", map[string]string{"#belongTo": "User", "#frobnicate": "true"})
+	v := newTestVersion(user, proposal)
+	v.Metadata = design.MetadataDefinition{META_NAMESPACE + RBAC: "true", META_NAMESPACE + TABLENAME: "users"}
+	want := []string{
+		"metadata #tablename: key does not apply to the API, only to models",
+		`type ProposalModel, metadata #belongTo: unknown gorma metadata key, did you mean "#belongsto"?`,
+		"type ProposalModel, metadata #frobnicate: unknown gorma metadata key",
+		"type UserModel, metadata #gormtag: key does not apply to models, only to attributes",
+		"type UserModel, attribute name, metadata #hasmany: key does not apply to attributes, only to models",
+	}
+	var got []string
+	for _, e := range validateMetadata(v) {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
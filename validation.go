@@ -0,0 +1,160 @@
+package gorma
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/raphael/goa/design"
+	"github.com/raphael/goa/goagen/codegen"
+)
+
+// goaImport is the import path of the goa package, the generated Validate
+// methods use it to check formats.
+const goaImport = "github.com/raphael/goa"
+
+// modelValidations returns the statements of the body of the Validate method
+// of a model. They check the fields against the validations of the design
+// attributes and append a ValidationError to errs for each failure.
+func modelValidations(md *ModelData) string {
+	def := md.TypeDef.Definition()
+	obj := def.Type.ToObject()
+	if obj == nil {
+		return ""
+	}
+	var stmts []string
+	obj.IterateAttributes(func(n string, att *design.AttributeDefinition) error {
+		if !att.Type.IsPrimitive() {
+			return nil
+		}
+		field := "m." + codegen.Goify(n, true)
+		pointer := fieldIsPointer(md, def, n)
+		if def.IsRequired(n) {
+			if pointer {
+				stmts = append(stmts, validationCheck(field+" == nil", n, "is required"))
+			} else if att.Type.Kind() == design.StringKind {
+				stmts = append(stmts, validationCheck(field+` == ""`, n, "is required"))
+			}
+		}
+		val := field
+		if pointer {
+			val = "*" + field
+		}
+		var checks []string
+		for _, v := range att.Validations {
+			if s := attributeCheck(md, att, n, val, v); s != "" {
+				checks = append(checks, s)
+			}
+		}
+		if len(checks) == 0 {
+			return nil
+		}
+		if pointer {
+			stmts = append(stmts, fmt.Sprintf("if %s != nil {\n%s\n}", field, strings.Join(checks, "\n")))
+		} else {
+			stmts = append(stmts, checks...)
+		}
+		return nil
+	})
+	return strings.Join(stmts, "\n")
+}
+
+// attributeCheck returns the statement checking the value val of the field
+// generated for the attribute n against the validation v.
+func attributeCheck(md *ModelData, att *design.AttributeDefinition, n, val string, v design.ValidationDefinition) string {
+	switch actual := v.(type) {
+	case *design.EnumValidationDefinition:
+		if _, ok := findEnum(md.Enums, n); ok {
+			// the method call dereferences pointer fields
+			return validationCheck(fmt.Sprintf("!%s.IsValid()", strings.TrimPrefix(val, "*")), n, fmt.Sprintf("must be one of %v", actual.Values))
+		}
+		lits := make([]string, len(actual.Values))
+		for i, ev := range actual.Values {
+			lits[i] = fmt.Sprintf("%#v", ev)
+		}
+		return fmt.Sprintf("switch %s {\ncase %s:\ndefault:\n%s\n}", val, strings.Join(lits, ", "),
+			validationAppend(n, fmt.Sprintf("must be one of %v", actual.Values)))
+	case *design.FormatValidationDefinition:
+		if att.Type.Kind() != design.StringKind {
+			return ""
+		}
+		return fmt.Sprintf("if err := goa.ValidateFormat(goa.Format(%q), %s); err != nil {\n%s\n}", actual.Format, val,
+			validationAppend(n, fmt.Sprintf("must be formatted as %s", actual.Format)))
+	case *design.PatternValidationDefinition:
+		if att.Type.Kind() != design.StringKind {
+			return ""
+		}
+		return fmt.Sprintf("if ok, _ := regexp.MatchString(%q, %s); !ok {\n%s\n}", actual.Pattern, val,
+			validationAppend(n, fmt.Sprintf("must match the regexp %s", actual.Pattern)))
+	case *design.MinLengthValidationDefinition:
+		if att.Type.Kind() != design.StringKind {
+			return ""
+		}
+		return validationCheck(fmt.Sprintf("len(%s) < %d", val, actual.MinLength), n,
+			fmt.Sprintf("length must be greater than or equal to %d", actual.MinLength))
+	case *design.MaxLengthValidationDefinition:
+		if att.Type.Kind() != design.StringKind {
+			return ""
+		}
+		return validationCheck(fmt.Sprintf("len(%s) > %d", val, actual.MaxLength), n,
+			fmt.Sprintf("length must be less than or equal to %d", actual.MaxLength))
+	case *design.MinimumValidationDefinition:
+		return validationCheck(fmt.Sprintf("%s < %s", numericValue(att, val, actual.Min), formatNumber(actual.Min)), n,
+			fmt.Sprintf("must be greater than or equal to %s", formatNumber(actual.Min)))
+	case *design.MaximumValidationDefinition:
+		return validationCheck(fmt.Sprintf("%s > %s", numericValue(att, val, actual.Max), formatNumber(actual.Max)), n,
+			fmt.Sprintf("must be less than or equal to %s", formatNumber(actual.Max)))
+	}
+	return ""
+}
+
+// numericValue returns the expression comparing val to the bound f: integer
+// fields are converted to float64 when the bound isn't a whole number.
+func numericValue(att *design.AttributeDefinition, val string, f float64) string {
+	if att.Type.Kind() == design.IntegerKind && f != math.Trunc(f) {
+		return fmt.Sprintf("float64(%s)", val)
+	}
+	return val
+}
+
+// validationCheck returns the statement appending a ValidationError when cond
+// is true.
+func validationCheck(cond, n, msg string) string {
+	return fmt.Sprintf("if %s {\n%s\n}", cond, validationAppend(n, msg))
+}
+
+// validationAppend returns the statement appending a ValidationError to errs.
+func validationAppend(n, msg string) string {
+	return fmt.Sprintf("errs = append(errs, ValidationError{Field: %q, Message: %s})", n, strconv.Quote(msg))
+}
+
+// hasFormatValidation returns true if one of the attributes of the model has
+// a Format validation, the generated code then imports goa.
+func hasFormatValidation(def *design.AttributeDefinition) bool {
+	found := false
+	if obj := def.Type.ToObject(); obj != nil {
+		obj.IterateAttributes(func(n string, att *design.AttributeDefinition) error {
+			for _, v := range att.Validations {
+				if _, ok := v.(*design.FormatValidationDefinition); ok && att.Type.Kind() == design.StringKind {
+					found = true
+				}
+			}
+			return nil
+		})
+	}
+	return found
+}
+
+// fieldIsPointer returns true if the struct field generated for the attribute
+// n of the model is a pointer, see ModelDef.
+func fieldIsPointer(md *ModelData, def *design.AttributeDefinition, n string) bool {
+	att := def.Type.ToObject()[n]
+	if _, ok := findPrimaryKey(md.PrimaryKeys, n); ok {
+		return false
+	}
+	if gt, ok := metaLookup(att.Metadata, GORMTAG); ok && strings.Contains(gt, "primary_key") {
+		return false
+	}
+	return att.Type.IsObject() || def.IsPrimitivePointer(n)
+}
@@ -0,0 +1,125 @@
+package gorma
+
+import (
+	"testing"
+
+	"github.com/raphael/goa/design"
+)
+
+func TestModelValidations(t *testing.T) {
+	required := &design.RequiredValidationDefinition{Names: []string{"name"}}
+	cases := []struct {
+		name     string
+		att      *design.AttributeDefinition
+		required bool
+		want     string
+	}{
+		{
+			name: "no validation",
+			att:  &design.AttributeDefinition{Type: design.String},
+		},
+		{
+			name:     "required string",
+			att:      &design.AttributeDefinition{Type: design.String},
+			required: true,
+			want:     "if m.Name == \"\" {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"is required\"})\n}",
+		},
+		{
+			name:     "required integer",
+			att:      &design.AttributeDefinition{Type: design.Integer},
+			required: true,
+		},
+		{
+			name: "optional string length",
+			att: &design.AttributeDefinition{Type: design.String, Validations: []design.ValidationDefinition{
+				&design.MinLengthValidationDefinition{MinLength: 2},
+				&design.MaxLengthValidationDefinition{MaxLength: 20},
+			}},
+			want: "if m.Name != nil {\n" +
+				"if len(*m.Name) < 2 {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"length must be greater than or equal to 2\"})\n}\n" +
+				"if len(*m.Name) > 20 {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"length must be less than or equal to 20\"})\n}\n}",
+		},
+		{
+			name: "pattern",
+			att: &design.AttributeDefinition{Type: design.String, Validations: []design.ValidationDefinition{
+				&design.PatternValidationDefinition{Pattern: `^\w+$`},
+			}},
+			required: true,
+			want: "if m.Name == \"\" {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"is required\"})\n}\n" +
+				"if ok, _ := regexp.MatchString(\"^\\\\w+$\", m.Name); !ok {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"must match the regexp ^\\\\w+$\"})\n}",
+		},
+		{
+			name: "format",
+			att: &design.AttributeDefinition{Type: design.String, DefaultValue: "a@b.c", Validations: []design.ValidationDefinition{
+				&design.FormatValidationDefinition{Format: "email"},
+			}},
+			want: "if err := goa.ValidateFormat(goa.Format(\"email\"), m.Name); err != nil {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"must be formatted as email\"})\n}",
+		},
+		{
+			name: "integer bounds",
+			att: &design.AttributeDefinition{Type: design.Integer, Validations: []design.ValidationDefinition{
+				&design.MinimumValidationDefinition{Min: 0.5},
+				&design.MaximumValidationDefinition{Max: 10},
+			}},
+			required: true,
+			want: "if float64(m.Name) < 0.5 {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"must be greater than or equal to 0.5\"})\n}\n" +
+				"if m.Name > 10 {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"must be less than or equal to 10\"})\n}",
+		},
+		{
+			name: "enum",
+			att: &design.AttributeDefinition{Type: design.String, Validations: []design.ValidationDefinition{
+				&design.EnumValidationDefinition{Values: []interface{}{"a", "b"}},
+			}},
+			want: "if m.Name != nil {\nif !m.Name.IsValid() {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"must be one of [a b]\"})\n}\n}",
+		},
+		{
+			name: "length of array",
+			att: &design.AttributeDefinition{
+				Type:        &design.Array{ElemType: &design.AttributeDefinition{Type: design.String}},
+				Validations: []design.ValidationDefinition{&design.MaxLengthValidationDefinition{MaxLength: 3}},
+			},
+		},
+		{
+			name: "primary key",
+			att: &design.AttributeDefinition{
+				Type:        design.Integer,
+				Metadata:    design.MetadataDefinition{META_NAMESPACE + GORMTAG: "primary_key"},
+				Validations: []design.ValidationDefinition{&design.MinimumValidationDefinition{Min: 1}},
+			},
+			want: "if m.Name < 1 {\nerrs = append(errs, ValidationError{Field: \"name\", Message: \"must be greater than or equal to 1\"})\n}",
+		},
+	}
+	for _, c := range cases {
+		utd := newTestModel("User", nil)
+		utd.Type = design.Object{"name": c.att}
+		if c.required {
+			utd.Validations = []design.ValidationDefinition{required}
+		}
+		md := &ModelData{TypeDef: utd, Enums: getEnums("User", utd.Definition())}
+		var err error
+		if md.PrimaryKeys, err = getPrimaryKeys(utd); err != nil {
+			t.Fatal(err)
+		}
+		if got := modelValidations(md); got != c.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestHasFormatValidation(t *testing.T) {
+	format := []design.ValidationDefinition{&design.FormatValidationDefinition{Format: "email"}}
+	cases := []struct {
+		name string
+		obj  design.Object
+		want bool
+	}{
+		{"none", design.Object{"email": &design.AttributeDefinition{Type: design.String}}, false},
+		{"string", design.Object{"email": &design.AttributeDefinition{Type: design.String, Validations: format}}, true},
+		{"integer", design.Object{"count": &design.AttributeDefinition{Type: design.Integer, Validations: format}}, false},
+	}
+	for _, c := range cases {
+		if got := hasFormatValidation(&design.AttributeDefinition{Type: c.obj}); got != c.want {
+			t.Errorf("%s: got %v want %v", c.name, got, c.want)
+		}
+	}
+}